@@ -60,3 +60,10 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Secret holding the webhook serving certificate
+*/}}
+{{- define "fleet-workspace-controller.webhookCertSecret" -}}
+{{- .Values.webhook.certSecret | default (printf "%s-webhook-tls" (include "fleet-workspace-controller.fullname" .)) }}
+{{- end }}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// creatorAdminAnnotation returns the gorizond-user.<creator>.admin annotation granting the
+// creator admin, valued with the creator's external principal when there is one.
+func creatorAdminAnnotation(users v3.UserCache, creator string) (string, string, error) {
+	return userMembershipAnnotation(users, creator, adminRole)
+}
+
+// initCreatorAdmin makes the creator of a new workspace its admin and marks the workspace
+// initialized. A workspace without a known creator cannot be fixed by retrying, it is left
+// uninitialized and reported in the CreatorMissing condition until the creator shows up.
+// It reports whether annotations changed.
+func initCreatorAdmin(users v3.UserCache, obj *managementv3.FleetWorkspace, status *workspaceStatus) (bool, error) {
+	creator := obj.Annotations["field.cattle.io/creatorId"]
+	if creator == "" {
+		status.set(conditionCreatorMissing, true, "NoCreator",
+			"the workspace has no field.cattle.io/creatorId annotation, add an admin annotation by hand")
+		return false, nil
+	}
+	key, principalID, err := creatorAdminAnnotation(users, creator)
+	if errors.IsNotFound(err) {
+		status.set(conditionCreatorMissing, true, "CreatorNotFound",
+			fmt.Sprintf("the creator %s does not exist, add an admin annotation by hand", creator))
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	obj.Annotations["workspace-roles-init"] = "true"
+	obj.Annotations[key] = principalID
+	if status.get(conditionCreatorMissing) != nil {
+		status.set(conditionCreatorMissing, false, "CreatorFound", "")
+	}
+	return true, nil
+}
+
+// userMembershipAnnotation returns the gorizond-user.<userID>.<role> annotation granting the
+// user a role, valued with the user's external principal when there is one.
+func userMembershipAnnotation(users v3.UserCache, userID, role string) (string, string, error) {
+	user, err := users.Get(userID)
+	if err != nil {
+		return "", "", err
+	}
+	principalID := "local://" + userID
+	for _, id := range user.PrincipalIDs {
+		if !strings.HasPrefix(id, "local://") {
+			principalID = id
+		}
+	}
+	return "gorizond-user." + userID + "." + role, principalID, nil
+}
+
+// guardLastAdmin keeps an initialized workspace from ending up without admins after its last
+// admin annotation was removed. The creator is restored as admin when it still exists,
+// otherwise the existing admin bindings are kept. It reports whether annotations changed and
+// whether admin bindings must survive the stale binding cleanup.
+func guardLastAdmin(users v3.UserCache, bindings v3.GlobalRoleBindingCache, obj *managementv3.FleetWorkspace, status *workspaceStatus) (bool, bool) {
+	if obj.Annotations["workspace-roles-init"] != "true" {
+		return false, false
+	}
+	creator := obj.Annotations["field.cattle.io/creatorId"]
+
+	admins, err := workspaceAdmins(bindings, obj)
+	if err != nil {
+		// without the bindings we cannot tell, keep them rather than risk a lockout
+		return false, true
+	}
+	if len(admins) > 0 {
+		// a restored creator stays flagged until someone else is made admin
+		if cond := status.get(conditionLastAdminRemoved); cond != nil && cond.Status == corev1.ConditionTrue &&
+			(cond.Reason != "CreatorRestored" || hasOtherAdmin(admins, creator)) {
+			status.set(conditionLastAdminRemoved, false, "AdminsPresent", "")
+		}
+		return false, false
+	}
+
+	if creator != "" {
+		key, value, err := creatorAdminAnnotation(users, creator)
+		if err == nil {
+			obj.Annotations[key] = value
+			status.set(conditionLastAdminRemoved, true, "CreatorRestored",
+				fmt.Sprintf("the last admin was removed, restored the creator %s as admin", creator))
+			return true, false
+		}
+	}
+	status.set(conditionLastAdminRemoved, true, "BindingsKept",
+		"the last admin was removed and the creator is gone, the existing admin bindings are kept")
+	return false, true
+}
+
+// workspaceAdmins lists who holds admin in the workspace: the subjects of admin membership
+// annotations and of admin bindings owned by WorkspaceMemberships.
+func workspaceAdmins(bindings v3.GlobalRoleBindingCache, obj *managementv3.FleetWorkspace) ([]string, error) {
+	var admins []string
+	for k := range obj.Annotations {
+		prefix := membershipPrefix(k)
+		if prefix == "" {
+			continue
+		}
+		if id, role, err := parseMembershipKey(prefix, k); err == nil && role == adminRole {
+			admins = append(admins, id)
+		}
+	}
+
+	list, err := bindings.List(labels.SelectorFromSet(labels.Set{"fleet": obj.Name}))
+	if err != nil {
+		return nil, err
+	}
+	adminGlobalRole := globalRoleName(adminRole, obj.Name)
+	for _, binding := range list {
+		if binding.Labels[membershipLabel] != "" && binding.GlobalRoleName == adminGlobalRole && binding.DeletionTimestamp == nil {
+			admins = append(admins, binding.UserName+binding.GroupPrincipalName)
+		}
+	}
+	return admins, nil
+}
+
+func hasOtherAdmin(admins []string, creator string) bool {
+	for _, admin := range admins {
+		if admin != creator {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdminBinding reports whether a binding grants admin in the workspace.
+func isAdminBinding(binding *managementv3.GlobalRoleBinding, workspace string) bool {
+	return binding.GlobalRoleName == globalRoleName(adminRole, workspace)
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"testing"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGuardLastAdmin(t *testing.T) {
+	users := newFakeCache(&managementv3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-creator"},
+		PrincipalIDs: []string{"local://u-creator", "github_user://42"},
+	})
+	membershipAdmin := &managementv3.GlobalRoleBinding{
+		ObjectMeta:     metav1.ObjectMeta{Name: "grb-member", Labels: map[string]string{"fleet": "workspace-a", membershipLabel: "m-1"}},
+		GlobalRoleName: globalRoleName(adminRole, "workspace-a"),
+		UserName:       "u-member",
+	}
+
+	workspace := func(creator string, annotations map[string]string) *managementv3.FleetWorkspace {
+		obj := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Annotations: map[string]string{
+			"workspace-roles-init":      "true",
+			"field.cattle.io/creatorId": creator,
+		}}}
+		for k, v := range annotations {
+			obj.Annotations[k] = v
+		}
+		return obj
+	}
+
+	tests := []struct {
+		name         string
+		obj          *managementv3.FleetWorkspace
+		bindings     []*managementv3.GlobalRoleBinding
+		condition    *workspaceStatus
+		wantRestored bool
+		wantKeep     bool
+		wantCond     corev1.ConditionStatus
+		wantReason   string
+	}{
+		{
+			name: "not initialized",
+			obj:  &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Annotations: map[string]string{}}},
+		},
+		{
+			name: "admin annotation present",
+			obj:  workspace("u-creator", map[string]string{"gorizond-user.u-other.admin": "local://u-other"}),
+		},
+		{
+			name:     "admin membership binding",
+			obj:      workspace("u-creator", nil),
+			bindings: []*managementv3.GlobalRoleBinding{membershipAdmin},
+		},
+		{
+			name:         "creator restored",
+			obj:          workspace("u-creator", map[string]string{"gorizond-user.u-other.view": "local://u-other"}),
+			wantRestored: true,
+			wantCond:     corev1.ConditionTrue,
+			wantReason:   "CreatorRestored",
+		},
+		{
+			name:       "creator gone",
+			obj:        workspace("u-deleted", nil),
+			wantKeep:   true,
+			wantCond:   corev1.ConditionTrue,
+			wantReason: "BindingsKept",
+		},
+		{
+			name:       "restored creator alone stays flagged",
+			obj:        workspace("u-creator", map[string]string{"gorizond-user.u-creator.admin": "github_user://42"}),
+			condition:  statusWith(conditionLastAdminRemoved, true, "CreatorRestored"),
+			wantCond:   corev1.ConditionTrue,
+			wantReason: "CreatorRestored",
+		},
+		{
+			name: "cleared once another admin is added",
+			obj: workspace("u-creator", map[string]string{
+				"gorizond-user.u-creator.admin": "github_user://42",
+				"gorizond-user.u-other.admin":   "local://u-other",
+			}),
+			condition:  statusWith(conditionLastAdminRemoved, true, "CreatorRestored"),
+			wantCond:   corev1.ConditionFalse,
+			wantReason: "AdminsPresent",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := workspaceStatus{}
+			if tt.condition != nil {
+				status = *tt.condition
+			}
+			restored, keep := guardLastAdmin(users, newFakeCache(tt.bindings...), tt.obj, &status)
+			if restored != tt.wantRestored || keep != tt.wantKeep {
+				t.Fatalf("guardLastAdmin() = %v, %v, want %v, %v", restored, keep, tt.wantRestored, tt.wantKeep)
+			}
+			if restored && tt.obj.Annotations["gorizond-user.u-creator.admin"] != "github_user://42" {
+				t.Errorf("creator admin annotation = %q, want the external principal", tt.obj.Annotations["gorizond-user.u-creator.admin"])
+			}
+
+			cond := status.get(conditionLastAdminRemoved)
+			if tt.wantCond == "" {
+				if cond != nil {
+					t.Errorf("unexpected condition %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != tt.wantCond || cond.Reason != tt.wantReason {
+				t.Errorf("condition = %+v, want %s %s", cond, tt.wantCond, tt.wantReason)
+			}
+		})
+	}
+}
+
+func statusWith(condType string, ok bool, reason string) *workspaceStatus {
+	status := &workspaceStatus{}
+	status.set(condType, ok, reason, "")
+	return status
+}
+
+func TestInitCreatorAdmin(t *testing.T) {
+	users := newFakeCache(&managementv3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-creator"},
+		PrincipalIDs: []string{"local://u-creator", "github_user://42"},
+	})
+
+	tests := []struct {
+		name       string
+		creator    string
+		condition  *workspaceStatus
+		wantInit   bool
+		wantCond   corev1.ConditionStatus
+		wantReason string
+	}{
+		{name: "creator", creator: "u-creator", wantInit: true},
+		{name: "no creator", wantCond: corev1.ConditionTrue, wantReason: "NoCreator"},
+		{name: "unknown creator", creator: "u-deleted", wantCond: corev1.ConditionTrue, wantReason: "CreatorNotFound"},
+		{
+			name:       "creator shows up",
+			creator:    "u-creator",
+			condition:  statusWith(conditionCreatorMissing, true, "CreatorNotFound"),
+			wantInit:   true,
+			wantCond:   corev1.ConditionFalse,
+			wantReason: "CreatorFound",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Annotations: map[string]string{}}}
+			if tt.creator != "" {
+				obj.Annotations["field.cattle.io/creatorId"] = tt.creator
+			}
+			status := workspaceStatus{}
+			if tt.condition != nil {
+				status = *tt.condition
+			}
+
+			initialized, err := initCreatorAdmin(users, obj, &status)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if initialized != tt.wantInit || (obj.Annotations["workspace-roles-init"] == "true") != tt.wantInit {
+				t.Fatalf("initCreatorAdmin() = %v, annotations %v, want initialized %v", initialized, obj.Annotations, tt.wantInit)
+			}
+			if tt.wantInit && obj.Annotations["gorizond-user.u-creator.admin"] != "github_user://42" {
+				t.Errorf("creator admin annotation = %q, want the external principal", obj.Annotations["gorizond-user.u-creator.admin"])
+			}
+
+			cond := status.get(conditionCreatorMissing)
+			if tt.wantCond == "" {
+				if cond != nil {
+					t.Errorf("unexpected condition %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != tt.wantCond || cond.Reason != tt.wantReason {
+				t.Errorf("condition = %+v, want %s %s", cond, tt.wantCond, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// configMapReader is the part of the ConfigMap cache handlers read their settings from.
+// start.All syncs every cache before any handler runs, the ConfigMap handlers themselves
+// may only run after the first reconciles.
+type configMapReader interface {
+	Get(namespace, name string) (*corev1.ConfigMap, error)
+}
+
+// configMapSetting is a setting stored under a key of a ConfigMap in the config namespace,
+// the fallback applies without the ConfigMap or key. An invalid value keeps the previous
+// one, and fails reads until a valid value was loaded once, so the fallback never acts in
+// place of a configured setting right after a restart.
+type configMapSetting[T any] struct {
+	name     func() string
+	key      string
+	parse    func([]byte) (T, error)
+	fallback func() T
+
+	mu      sync.RWMutex
+	value   T
+	loaded  bool
+	seen    bool
+	present bool
+	data    string
+	err     error
+}
+
+func newConfigMapSetting[T any](name func() string, key string, parse func([]byte) (T, error), fallback func() T) *configMapSetting[T] {
+	return &configMapSetting[T]{name: name, key: key, parse: parse, fallback: fallback, value: fallback()}
+}
+
+// sync makes the value of obj, nil when the ConfigMap does not exist, the active one and
+// reports whether it changed. It returns the parse error of an invalid value, also when
+// the value was already seen.
+func (s *configMapSetting[T]) sync(obj *corev1.ConfigMap) (bool, error) {
+	var data string
+	present := false
+	if obj != nil && obj.DeletionTimestamp == nil {
+		data, present = obj.Data[s.key]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen && present == s.present && data == s.data {
+		return false, s.err
+	}
+	s.seen, s.present, s.data = true, present, data
+
+	value := s.fallback()
+	if present {
+		parsed, err := s.parse([]byte(data))
+		if err != nil {
+			s.err = err
+			return false, err
+		}
+		value = parsed
+	}
+	s.err = nil
+	s.loaded = true
+	if reflect.DeepEqual(value, s.value) {
+		return false, nil
+	}
+	s.value = value
+	return true, nil
+}
+
+// read syncs the setting from the ConfigMap cache and returns the active value. It fails
+// while the ConfigMap holds an invalid value and no valid one was loaded before.
+func (s *configMapSetting[T]) read(configMaps configMapReader) (T, error) {
+	var zero T
+	obj, err := configMaps.Get(ConfigNamespace(), s.name())
+	if errors.IsNotFound(err) {
+		obj, err = nil, nil
+	}
+	if err != nil {
+		return zero, err
+	}
+
+	_, err = s.sync(obj)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err != nil && !s.loaded {
+		return zero, fmt.Errorf("invalid %s in ConfigMap %s/%s: %w", s.key, ConfigNamespace(), s.name(), err)
+	}
+	return s.value, nil
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeConfigMaps serves ConfigMaps of the config namespace by name.
+type fakeConfigMaps map[string]*corev1.ConfigMap
+
+func (f fakeConfigMaps) Get(namespace, name string) (*corev1.ConfigMap, error) {
+	if obj, ok := f[name]; ok && namespace == ConfigNamespace() {
+		return obj, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+}
+
+func configMapWith(name, key, data string) fakeConfigMaps {
+	return fakeConfigMaps{name: {
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ConfigNamespace()},
+		Data:       map[string]string{key: data},
+	}}
+}
+
+func TestConfigMapSetting(t *testing.T) {
+	const customCatalog = "roles:\n- name: admin\n- name: deployer\n"
+	setting := newConfigMapSetting(getRoleCatalogName, roleCatalogKey, parseRoleCatalog, defaultRoleCatalog)
+	roles := func(catalog *RoleCatalog) int { return len(catalog.Roles) }
+
+	// a typo right after a restart must not let the built-in catalog act
+	if _, err := setting.read(configMapWith(getRoleCatalogName(), roleCatalogKey, "roles: [")); err == nil {
+		t.Fatal("expected an error before a valid catalog was loaded")
+	}
+
+	catalog, err := setting.read(configMapWith(getRoleCatalogName(), roleCatalogKey, customCatalog))
+	if err != nil || roles(catalog) != 2 {
+		t.Fatalf("read() = %v, %v, want the custom catalog", catalog, err)
+	}
+
+	// once loaded, a typo keeps the previous catalog
+	catalog, err = setting.read(configMapWith(getRoleCatalogName(), roleCatalogKey, "roles: ["))
+	if err != nil || roles(catalog) != 2 {
+		t.Fatalf("read() = %v, %v, want the previous catalog", catalog, err)
+	}
+	if _, err := setting.sync(configMapWith(getRoleCatalogName(), roleCatalogKey, "roles: [")[getRoleCatalogName()]); err == nil {
+		t.Fatal("expected sync to report the invalid catalog")
+	}
+
+	changed, err := setting.sync(nil)
+	if err != nil || !changed {
+		t.Fatalf("sync(nil) = %v, %v, want the built-in catalog", changed, err)
+	}
+	if catalog, err := setting.read(fakeConfigMaps{}); err != nil || roles(catalog) != 3 {
+		t.Fatalf("read() = %v, %v, want the built-in catalog", catalog, err)
+	}
+	if changed, _ := setting.sync(nil); changed {
+		t.Fatal("expected an unchanged ConfigMap to be a no-op")
+	}
+}
@@ -2,15 +2,29 @@ package controllers
 
 import (
 	"context"
+	goerrors "errors"
+	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
 	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	v3 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	workspace "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
 	"github.com/rancher/lasso/pkg/log"
-	rbacv1 "k8s.io/api/rbac/v1"
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
@@ -25,111 +39,49 @@ type fleetWorkspaceDeleter interface {
 	Delete(name string, options *metav1.DeleteOptions) error
 }
 
-func InitFleetWorkspaceController(ctx context.Context, mgmt *management.Factory) {
+type fleetWorkspaceHandler struct {
+	ctx                context.Context
+	provisioner        *userProvisioner
+	users              v3.UserCache
+	userClient         userPatcher
+	userAttributes     userAttributeGetter
+	fleetWorkspaces    v3.FleetWorkspaceController
+	globalRoles        v3.GlobalRoleController
+	globalRoleBindings v3.GlobalRoleBindingController
+	recorder           record.EventRecorder
+	templates          workspaceTemplateGetter
+	applyTemplate      templateApplier
+	configMaps         configMapReader
+}
+
+func InitFleetWorkspaceController(ctx context.Context, mgmt *management.Factory, ws *workspace.Factory, configMaps corecontrollers.ConfigMapCache, apply apply.Apply, recorder record.EventRecorder, rancher RancherAPI) {
 	fleetWorkspaces := mgmt.Management().V3().FleetWorkspace()
 	users := mgmt.Management().V3().User()
-	principal := mgmt.Management().V3().Principal()
 	globalRoleBinding := mgmt.Management().V3().GlobalRoleBinding()
-	fleetWorkspaces.OnChange(ctx, "gorizond-fleetworkspace-controller", func(key string, obj *managementv3.FleetWorkspace) (*managementv3.FleetWorkspace, error) {
-		if obj == nil {
-			return nil, nil
-		}
-		// ignore default workspaces
-		if obj.Name == "fleet-default" || obj.Name == "fleet-local" {
-			return nil, nil
-		}
-
-		deleted, err := ensureWorkspacePrefix(fleetWorkspaces, obj, workspacePrefix)
-		if err != nil {
-			return obj, err
-		}
-		if deleted {
-			return obj, nil
-		}
-
-		//
-		//
-		//
-		// Create or update global role bindings based on annotations
-		for k, v := range obj.Annotations {
-			if strings.HasPrefix(k, "gorizond-user.") {
-				createGlobalRoleBinding(globalRoleBinding, "gorizond-user.", obj.Name, k)
-			}
-			if strings.HasPrefix(k, "gorizond-group.") {
-				createGlobalRoleBindingForGroup(globalRoleBinding, "gorizond-group.", obj.Name, k, v)
-			}
-			if strings.HasPrefix(k, "gorizond-principal.") {
-				return findByPrincipal(users, principal, globalRoleBinding, obj, fleetWorkspaces, k, v)
-			}
-		}
-
-		// List all global role bindings with the label `fleet: <fleetWorkspace>`
-		globalRoleBindings, err := mgmt.Management().V3().GlobalRoleBinding().List(metav1.ListOptions{
-			LabelSelector: "fleet=" + obj.Name,
-		})
-		if err != nil {
-			log.Infof("Failed to list global role bindings: %v", err)
-			return obj, nil
-		}
-
-		// Delete global role bindings that do not have corresponding annotations
-		for _, binding := range globalRoleBindings.Items {
-			found := false
-			for k := range obj.Annotations {
-				if strings.HasPrefix(k, "gorizond-user.") && k == binding.Annotations["gorizond-binding"] {
-					found = true
-					break
-				}
-				if strings.HasPrefix(k, "gorizond-group.") && k == binding.Annotations["gorizond-binding"] {
-					found = true
-					break
-				}
-			}
-			if !found {
-				err := mgmt.Management().V3().GlobalRoleBinding().Delete(binding.Name, nil)
-				if err != nil && !errors.IsNotFound(err) {
-					log.Infof("Failed to delete global role binding %s: %v", binding.Name, err)
-				}
-			}
-		}
-		//
-		// create ROLES
-		//
-		// check rules init on workspace create
-		firstInit := obj.Annotations != nil && obj.Annotations["workspace-roles-init"] == "true"
-
-		if firstInit {
-			return obj, nil
-		}
-
-		// Create roles
-		createRole(mgmt, obj, "admin", []string{"*"}, obj.Annotations["field.cattle.io/creatorId"])
-		createRole(mgmt, obj, "editor", []string{"get", "list", "watch", "update", "patch"}, obj.Annotations["field.cattle.io/creatorId"])
-		createRole(mgmt, obj, "view", []string{"get", "list", "watch"}, obj.Annotations["field.cattle.io/creatorId"])
-
-		obj = obj.DeepCopy()
-		// Add annotation
-		if obj.Annotations == nil {
-			obj.Annotations = make(map[string]string)
-		}
-		obj.Annotations["workspace-roles-init"] = "true"
-		// find principal for user if exist
-		searchedUser, err := findUserByUsername(os.Getenv("RANCHER_URL"), os.Getenv("RANCHER_TOKEN"), "/v3/user?id="+obj.Annotations["field.cattle.io/creatorId"])
-		if err != nil {
-			return nil, err
-		}
-		principalId := "local://" + obj.Annotations["field.cattle.io/creatorId"]
-		for _, iterPrincipal := range searchedUser.Data[0].PrincipalIDs {
-			if !strings.HasPrefix(iterPrincipal, "local://") {
-				principalId = iterPrincipal
-			}
-		}
-		obj.Annotations["gorizond-user."+obj.Annotations["field.cattle.io/creatorId"]+".admin"] = principalId
-
-		return fleetWorkspaces.Update(obj)
-	},
-	)
-	fleetWorkspaces.OnRemove(ctx, "gorizond-workspace-delete", func(key string, obj *managementv3.FleetWorkspace) (*managementv3.FleetWorkspace, error) {
+	h := &fleetWorkspaceHandler{
+		ctx:                ctx,
+		provisioner:        newUserProvisioner(rancher, users),
+		users:              users.Cache(),
+		userClient:         users,
+		userAttributes:     mgmt.Management().V3().UserAttribute().Cache(),
+		fleetWorkspaces:    fleetWorkspaces,
+		globalRoles:        mgmt.Management().V3().GlobalRole(),
+		globalRoleBindings: globalRoleBinding,
+		recorder:           recorder,
+		templates:          ws.Workspace().V1().WorkspaceTemplate().Cache(),
+		applyTemplate:      newTemplateApplier(apply),
+		configMaps:         configMaps,
+	}
+	// workspace gauges are computed from the caches on every scrape
+	metrics.Registry.MustRegister(&workspaceCollector{
+		fleetWorkspaces:    fleetWorkspaces.Cache(),
+		globalRoleBindings: globalRoleBinding.Cache(),
+	})
+	// edits and deletes of workspace roles and bindings re-trigger the owning workspace,
+	// so the state derived from its annotations is restored
+	relatedresource.WatchClusterScoped(ctx, "gorizond-workspace-rbac-watch", resolveFleetWorkspace, fleetWorkspaces, mgmt.Management().V3().GlobalRole(), globalRoleBinding)
+	fleetWorkspaces.OnChange(ctx, "gorizond-fleetworkspace-controller", skipLivenessProbe(metrics.Instrument("gorizond-fleetworkspace-controller", h.onChange)))
+	fleetWorkspaces.OnRemove(ctx, "gorizond-workspace-delete", skipLivenessProbe(metrics.Instrument("gorizond-workspace-delete", func(key string, obj *managementv3.FleetWorkspace) (*managementv3.FleetWorkspace, error) {
 		if obj == nil {
 			return nil, nil
 		}
@@ -181,20 +133,257 @@ func InitFleetWorkspaceController(ctx context.Context, mgmt *management.Factory)
 			return obj, nil
 		}
 
-		if err := mgmt.Management().V3().GlobalRole().Delete("gorizond-admin-"+obj.Name, nil); err != nil && !errors.IsNotFound(err) {
-			return nil, err
-		}
-		if err := mgmt.Management().V3().GlobalRole().Delete("gorizond-editor-"+obj.Name, nil); err != nil && !errors.IsNotFound(err) {
+		globalRoles, err := mgmt.Management().V3().GlobalRole().List(metav1.ListOptions{
+			LabelSelector: "fleet=" + obj.Name,
+		})
+		if err != nil {
 			return nil, err
 		}
-		if err := mgmt.Management().V3().GlobalRole().Delete("gorizond-view-"+obj.Name, nil); err != nil && !errors.IsNotFound(err) {
-			return nil, err
+		for _, globalRole := range globalRoles.Items {
+			if err := mgmt.Management().V3().GlobalRole().Delete(globalRole.Name, nil); err != nil && !errors.IsNotFound(err) {
+				return nil, err
+			}
 		}
 		return obj, nil
-	},
+	})),
 	)
 }
 
+// resolveFleetWorkspace maps a gorizond object labelled `fleet: <workspace>` to its FleetWorkspace.
+func resolveFleetWorkspace(_, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+	if obj == nil || !strings.HasPrefix(name, "gorizond-") {
+		return nil, nil
+	}
+	meta, err := apimeta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	workspace := meta.GetLabels()["fleet"]
+	if workspace == "" {
+		return nil, nil
+	}
+	return []relatedresource.Key{{Name: workspace}}, nil
+}
+
+func (h *fleetWorkspaceHandler) onChange(key string, obj *managementv3.FleetWorkspace) (*managementv3.FleetWorkspace, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	// ignore default workspaces
+	if isDefaultWorkspace(obj.Name) {
+		return nil, nil
+	}
+
+	deleted, err := ensureWorkspacePrefix(h.fleetWorkspaces, obj, workspacePrefix)
+	if err != nil {
+		return obj, err
+	}
+	if deleted {
+		return obj, nil
+	}
+	rejected, err := h.ensureWorkspaceQuota(obj)
+	if err != nil || rejected {
+		return obj, err
+	}
+
+	// work on a copy, annotation and status changes are saved with a single update at the end
+	obj = obj.DeepCopy()
+	if obj.Annotations == nil {
+		obj.Annotations = make(map[string]string)
+	}
+	status := readWorkspaceStatus(obj)
+
+	// hand the workspace to a new owner before bindings are derived from the annotations
+	transferred, err := h.transferOwnership(obj, &status)
+	if err != nil {
+		return h.save(obj, status, false, err)
+	}
+
+	// Resolve all pending principals into user and group annotations
+	dirty, principalErr := h.resolvePrincipals(obj, &status)
+	dirty = dirty || transferred
+
+	// store expiry durations as timestamps so the TTL controller can enforce them
+	dirty = h.normalizeMembershipExpiry(obj) || dirty
+
+	// never let the stale binding cleanup below remove the last admin
+	restored, keepAdmins := guardLastAdmin(h.users, h.globalRoleBindings.Cache(), obj, &status)
+	dirty = dirty || restored
+
+	// Create or update global role bindings based on annotations
+	var bindErrs []string
+	for k, v := range obj.Annotations {
+		var err error
+		if strings.HasPrefix(k, "gorizond-user.") {
+			err = createGlobalRoleBinding(h.globalRoleBindings, "gorizond-user.", obj.Name, k, v)
+		}
+		if strings.HasPrefix(k, "gorizond-group.") {
+			err = createGlobalRoleBindingForGroup(h.globalRoleBindings, "gorizond-group.", obj.Name, k, v)
+		}
+		if err != nil {
+			bindErrs = append(bindErrs, fmt.Sprintf("%s: %v", k, err))
+		}
+	}
+	if len(bindErrs) > 0 {
+		sort.Strings(bindErrs)
+		status.set(conditionMembersBound, false, "BindingFailed", strings.Join(bindErrs, "; "))
+	} else {
+		status.set(conditionMembersBound, true, "Bound", "")
+	}
+
+	// List all global role bindings with the label `fleet: <fleetWorkspace>`
+	globalRoleBindings, err := h.globalRoleBindings.Cache().List(labels.SelectorFromSet(labels.Set{"fleet": obj.Name}))
+	if err != nil {
+		log.Infof("Failed to list global role bindings: %v", err)
+		return h.save(obj, status, dirty, principalErr)
+	}
+
+	// Delete global role bindings that do not have corresponding annotations
+	for _, binding := range globalRoleBindings {
+		// bindings driven by WorkspaceMembership objects are managed by their own controller
+		if binding.Labels[membershipLabel] != "" {
+			continue
+		}
+		if keepAdmins && isAdminBinding(binding, obj.Name) {
+			continue
+		}
+		found := false
+		for k := range obj.Annotations {
+			if strings.HasPrefix(k, "gorizond-user.") && k == binding.Annotations["gorizond-binding"] {
+				found = true
+				break
+			}
+			if strings.HasPrefix(k, "gorizond-group.") && k == binding.Annotations["gorizond-binding"] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			err := h.globalRoleBindings.Delete(binding.Name, nil)
+			if err != nil && !errors.IsNotFound(err) {
+				log.Infof("Failed to delete global role binding %s: %v", binding.Name, err)
+			}
+		}
+	}
+	//
+	// create ROLES
+	//
+	// roles are reconciled against the catalog on every sync to repair drift
+	catalog, err := roleCatalog.read(h.configMaps)
+	if err == nil {
+		err = ensureRoles(h.globalRoles, obj, catalog, obj.Annotations["field.cattle.io/creatorId"])
+	}
+	if err != nil {
+		status.set(conditionRolesReady, false, "SyncFailed", err.Error())
+		return h.save(obj, status, dirty, err)
+	}
+	status.set(conditionRolesReady, true, "Synced", "")
+
+	// seed the namespace from the workspace template once, and again on request
+	seeded, templateErr := h.seedTemplate(obj, &status)
+	dirty = dirty || seeded
+	syncErr := goerrors.Join(principalErr, templateErr)
+
+	// check rules init on workspace create
+	firstInit := obj.Annotations["workspace-roles-init"] == "true"
+
+	if firstInit {
+		return h.save(obj, status, dirty, syncErr)
+	}
+
+	// grant the creator admin, a missing creator is reported in status instead of retried
+	initialized, err := initCreatorAdmin(h.users, obj, &status)
+	return h.save(obj, status, dirty || initialized, goerrors.Join(syncErr, err))
+}
+
+// resolvePrincipals turns every gorizond-principal. annotation of obj into a user or group
+// annotation. Principals that fail keep their annotation and are retried on the next sync.
+// Principals waiting for their User record when it was first requested and are re-checked
+// until userProvisioningTimeout, after which they are only reported. It reports whether
+// annotations changed and returns an error when any principal failed.
+func (h *fleetWorkspaceHandler) resolvePrincipals(obj *managementv3.FleetWorkspace, status *workspaceStatus) (bool, error) {
+	var pending []string
+	for k := range obj.Annotations {
+		if strings.HasPrefix(k, "gorizond-principal.") {
+			pending = append(pending, k)
+		}
+	}
+	sort.Strings(pending)
+
+	var failures, waiting, timedOut []string
+	requested := map[string]bool{}
+	recorded := false
+	for _, k := range pending {
+		principalID := obj.Annotations[k]
+		err := resolvePrincipalAnnotation(h.ctx, h.provisioner, obj, k, principalID)
+		var pendingErr *userPendingError
+		var timeoutErr *userProvisioningTimeoutError
+		switch {
+		case err == nil:
+		case goerrors.As(err, &pendingErr):
+			waiting = append(waiting, principalID)
+			key := provisioningAnnotation(principalID)
+			requested[key] = true
+			if _, ok := obj.Annotations[key]; !ok {
+				obj.Annotations[key] = pendingErr.requested.UTC().Format(time.RFC3339)
+				recorded = true
+			}
+		case goerrors.As(err, &timeoutErr):
+			log.Infof("Gave up resolving principal %s for workspace %s: %v", principalID, obj.Name, err)
+			timedOut = append(timedOut, principalID)
+			requested[provisioningAnnotation(principalID)] = true
+		default:
+			log.Infof("Failed to resolve principal %s for workspace %s: %v", principalID, obj.Name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", principalID, err))
+		}
+	}
+	// provisioning annotations of principals that resolved or were removed are done
+	for k := range obj.Annotations {
+		if strings.HasPrefix(k, provisioningAnnotationPrefix) && !requested[k] {
+			delete(obj.Annotations, k)
+			recorded = true
+		}
+	}
+	resolved := len(pending) - len(failures) - len(waiting) - len(timedOut)
+
+	switch {
+	case len(timedOut) > 0:
+		status.set(conditionUsersProvisioned, false, "ProvisioningTimeout", strings.Join(timedOut, ", "))
+	case len(waiting) > 0:
+		status.set(conditionUsersProvisioned, false, "Provisioning", strings.Join(waiting, ", "))
+	default:
+		status.set(conditionUsersProvisioned, true, "Provisioned", "")
+	}
+	if len(waiting) > 0 {
+		h.fleetWorkspaces.EnqueueAfter(obj.Name, userProvisioningRetry)
+	}
+
+	if len(failures) > 0 {
+		status.set(conditionPrincipalResolutionFailed, true, "ResolveFailed", strings.Join(failures, "; "))
+		return resolved > 0 || recorded, fmt.Errorf("failed to resolve %d of %d principals", len(failures), len(pending))
+	}
+	status.set(conditionPrincipalResolutionFailed, false, "Resolved", "")
+	return resolved > 0 || recorded, nil
+}
+
+// save persists annotation and condition changes of the workspace copy in a single update
+// and passes err through.
+func (h *fleetWorkspaceHandler) save(obj *managementv3.FleetWorkspace, status workspaceStatus, dirty bool, err error) (*managementv3.FleetWorkspace, error) {
+	changed := applyWorkspaceStatus(obj, status)
+	if !dirty && len(changed) == 0 {
+		return obj, err
+	}
+	updated, updateErr := h.fleetWorkspaces.Update(obj)
+	if updateErr != nil {
+		if err == nil {
+			err = updateErr
+		}
+		return obj, err
+	}
+	recordConditionEvents(h.recorder, updated, changed)
+	return updated, err
+}
+
 func ensureWorkspacePrefix(fleetWorkspaces fleetWorkspaceDeleter, obj *managementv3.FleetWorkspace, expectedPrefix string) (bool, error) {
 	if strings.HasPrefix(obj.Name, expectedPrefix) {
 		return false, nil
@@ -217,67 +406,98 @@ func getWorkspacePrefix() string {
 	return defaultWorkspacePrefix
 }
 
-func createRole(mgmt *management.Factory, fleetworkspace *managementv3.FleetWorkspace, role string, verbs []string, userID string) {
-	roleName := "gorizond-" + role + "-" + fleetworkspace.Name
-	billingVerbs := []string{"get", "list", "watch"}
-	if role == "admin" {
-		billingVerbs = []string{"create", "delete", "get", "list", "watch"}
-	}
-	globalRole := &managementv3.GlobalRole{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: roleName,
-			Annotations: map[string]string{
-				"field.cattle.io/creatorId": userID,
-			},
-			Labels: map[string]string{
-				"role":  role,
-				"fleet": fleetworkspace.Name,
-			},
-		},
-		DisplayName: "GitOps for " + role + " " + fleetworkspace.Name,
-		NamespacedRules: map[string][]rbacv1.PolicyRule{
-			fleetworkspace.Name: {
-				{
-					APIGroups: []string{"fleet.cattle.io"},
-					Resources: []string{"gitrepos", "bundles", "clusterregistrationtokens", "gitreporestrictions", "clusters", "clustergroups"},
-					Verbs:     verbs,
-				},
-				{
-					APIGroups: []string{"provisioning.gorizond.io"},
-					Resources: []string{"clusters"},
-					Verbs:     verbs,
-				},
-				{
-					APIGroups: []string{"provisioning.gorizond.io"},
-					Resources: []string{"billings", "billingevents"},
-					Verbs:     billingVerbs,
-				},
-			},
-		},
-		Rules: []rbacv1.PolicyRule{
-			{
-				APIGroups:     []string{"management.cattle.io"},
-				ResourceNames: []string{fleetworkspace.Name},
-				Resources:     []string{"fleetworkspaces"},
-				Verbs:         verbs,
-			},
-			{
-				APIGroups:     []string{"provisioning.gorizond.io"},
-				ResourceNames: []string{fleetworkspace.Name},
-				Resources:     []string{"clusters"},
-				Verbs:         verbs,
-			},
-			{
-				APIGroups:     []string{"provisioning.gorizond.io"},
-				ResourceNames: []string{fleetworkspace.Name},
-				Resources:     []string{"billings", "billingevents"},
-				Verbs:         billingVerbs,
-			},
-		},
-	}
-
-	_, err := mgmt.Management().V3().GlobalRole().Create(globalRole)
-	if err != nil && !errors.IsAlreadyExists(err) {
-		log.Infof("Failed to create global role: %v", err)
+// ensureRoles creates the catalog GlobalRoles for a workspace, corrects drift on
+// the existing ones and removes roles that are no longer in the catalog.
+func ensureRoles(globalRoles v3.GlobalRoleController, fleetworkspace *managementv3.FleetWorkspace, catalog *RoleCatalog, userID string) error {
+	for _, role := range catalog.Roles {
+		desired, err := role.render(fleetworkspace)
+		if err != nil {
+			return err
+		}
+		desired.Annotations = map[string]string{
+			"field.cattle.io/creatorId": userID,
+		}
+
+		existing, err := globalRoles.Cache().Get(desired.Name)
+		if errors.IsNotFound(err) {
+			if _, err := globalRoles.Create(desired); err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		drift := globalRoleDrift(existing, desired)
+		if len(drift) == 0 {
+			continue
+		}
+		if _, err := globalRoles.Update(applyGlobalRole(existing, desired)); err != nil {
+			return err
+		}
+		log.Infof("Corrected drift on global role %s for workspace %s: %s", existing.Name, fleetworkspace.Name, strings.Join(drift, ", "))
+	}
+
+	// drop roles removed from the catalog
+	labelled, err := globalRoles.Cache().List(labels.SelectorFromSet(labels.Set{"fleet": fleetworkspace.Name}))
+	if err != nil {
+		return err
+	}
+	for _, globalRole := range labelled {
+		if _, ok := catalog.Role(globalRole.Labels["role"]); ok {
+			continue
+		}
+		if err := globalRoles.Delete(globalRole.Name, nil); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		log.Infof("Deleted global role %s not present in role catalog", globalRole.Name)
+	}
+	return nil
+}
+
+// globalRoleDrift lists the fields of a live GlobalRole that differ from the desired one.
+func globalRoleDrift(existing, desired *managementv3.GlobalRole) []string {
+	var drift []string
+	for k, v := range desired.Labels {
+		if existing.Labels[k] != v {
+			drift = append(drift, "labels")
+			break
+		}
+	}
+	if existing.Annotations["field.cattle.io/creatorId"] != desired.Annotations["field.cattle.io/creatorId"] {
+		drift = append(drift, "creator")
+	}
+	if existing.DisplayName != desired.DisplayName {
+		drift = append(drift, "displayName")
+	}
+	if !equality.Semantic.DeepEqual(existing.Rules, desired.Rules) {
+		drift = append(drift, "rules")
+	}
+	if !equality.Semantic.DeepEqual(existing.NamespacedRules, desired.NamespacedRules) {
+		drift = append(drift, "namespacedRules")
+	}
+	return drift
+}
+
+// applyGlobalRole returns a copy of existing carrying the managed fields of desired.
+// Labels and annotations not owned by the controller are preserved.
+func applyGlobalRole(existing, desired *managementv3.GlobalRole) *managementv3.GlobalRole {
+	updated := existing.DeepCopy()
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		updated.Labels[k] = v
+	}
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	for k, v := range desired.Annotations {
+		updated.Annotations[k] = v
 	}
+	updated.DisplayName = desired.DisplayName
+	updated.Rules = desired.Rules
+	updated.NamespacedRules = desired.NamespacedRules
+	return updated
 }
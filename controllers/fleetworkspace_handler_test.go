@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"strings"
 	"testing"
 
 	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
@@ -86,3 +87,42 @@ func TestEnsureWorkspacePrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestGlobalRoleDrift(t *testing.T) {
+	ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-demo"}}
+	editor, _ := defaultRoleCatalog().Role("editor")
+	desired, err := editor.render(ws)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	inSync := desired.DeepCopy()
+	inSync.Labels["extra"] = "kept"
+	if drift := globalRoleDrift(inSync, desired); len(drift) != 0 {
+		t.Fatalf("expected no drift, got %v", drift)
+	}
+
+	desired.Annotations = map[string]string{"field.cattle.io/creatorId": "u-new"}
+	edited := desired.DeepCopy()
+	edited.Annotations["field.cattle.io/creatorId"] = "u-old"
+	edited.DisplayName = "hand edited"
+	edited.Rules[0].Verbs = []string{"*"}
+	delete(edited.Labels, "role")
+	edited.Labels["extra"] = "kept"
+	drift := globalRoleDrift(edited, desired)
+	want := []string{"labels", "creator", "displayName", "rules"}
+	if strings.Join(drift, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected drift %v, got %v", want, drift)
+	}
+
+	fixed := applyGlobalRole(edited, desired)
+	if drift := globalRoleDrift(fixed, desired); len(drift) != 0 {
+		t.Fatalf("expected drift corrected, got %v", drift)
+	}
+	if fixed.Labels["extra"] != "kept" {
+		t.Fatalf("expected foreign labels to be preserved, got %v", fixed.Labels)
+	}
+	if edited.DisplayName != "hand edited" {
+		t.Fatalf("applyGlobalRole must not mutate the live object")
+	}
+}
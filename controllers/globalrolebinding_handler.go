@@ -6,12 +6,13 @@ import (
 
 	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
 	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
 )
 
 func InitGlobalRoleBindingController(ctx context.Context, mgmt *management.Factory) {
 	globalRoles := mgmt.Management().V3().GlobalRole()
 	globalRoleBinding := mgmt.Management().V3().GlobalRoleBinding()
-	globalRoles.OnChange(ctx, "gorizond-admin-bindings-controller", func(key string, obj *managementv3.GlobalRole) (*managementv3.GlobalRole, error) {
+	globalRoles.OnChange(ctx, "gorizond-admin-bindings-controller", metrics.Instrument("gorizond-admin-bindings-controller", func(key string, obj *managementv3.GlobalRole) (*managementv3.GlobalRole, error) {
 		if obj == nil {
 			return nil, nil
 		}
@@ -33,7 +34,9 @@ func InitGlobalRoleBindingController(ctx context.Context, mgmt *management.Facto
 		// set user as admin for workspace
 		userID := obj.Annotations["field.cattle.io/creatorId"]
 		FleetName := obj.Labels["fleet"]
-		createGlobalRoleBinding(globalRoleBinding, "gorizond-user.", FleetName, "gorizond-user."+userID+".admin")
+		if err := createGlobalRoleBinding(globalRoleBinding, "gorizond-user.", FleetName, "gorizond-user."+userID+".admin", ""); err != nil {
+			return obj, err
+		}
 
 		obj = obj.DeepCopy()
 		// Add annotation
@@ -43,5 +46,5 @@ func InitGlobalRoleBindingController(ctx context.Context, mgmt *management.Facto
 		obj.Annotations["global-role-init"] = "true"
 
 		return globalRoles.Update(obj)
-	})
+	}))
 }
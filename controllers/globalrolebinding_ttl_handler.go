@@ -2,48 +2,95 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"time"
 
 	v3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	managementGlobalRoleBinding "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	v3controllers "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
 	"github.com/rancher/lasso/pkg/log"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	managementGlobalRoleBinding "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	"k8s.io/client-go/tools/record"
 )
 
-func InitGlobalRoleBindingTTLController(ctx context.Context, mgmt *managementGlobalRoleBinding.Factory) {
+// ttlLabel holds the lifetime of a GlobalRoleBinding in seconds from its creation.
+const ttlLabel = "gorizond-ttl"
+
+// globalRoleBindingExpirer is the part of the GlobalRoleBinding controller the TTL handler needs.
+type globalRoleBindingExpirer interface {
+	Delete(name string, options *metav1.DeleteOptions) error
+	EnqueueAfter(name string, duration time.Duration)
+	Update(*v3.GlobalRoleBinding) (*v3.GlobalRoleBinding, error)
+}
+
+type globalRoleBindingTTLHandler struct {
+	globalRoleBindings globalRoleBindingExpirer
+	fleetWorkspaces    v3controllers.FleetWorkspaceCache
+	workspaceClient    fleetWorkspacePatcher
+	recorder           record.EventRecorder
+}
+
+func InitGlobalRoleBindingTTLController(ctx context.Context, mgmt *managementGlobalRoleBinding.Factory, recorder record.EventRecorder) {
 	globalRoleBindings := mgmt.Management().V3().GlobalRoleBinding()
-	globalRoleBindings.OnChange(ctx, "gorizond-grb-ttl-controller", func(key string, obj *v3.GlobalRoleBinding) (*v3.GlobalRoleBinding, error) {
-		if obj == nil {
-			return nil, nil
-		}
+	fleetWorkspaces := mgmt.Management().V3().FleetWorkspace()
+	h := &globalRoleBindingTTLHandler{
+		globalRoleBindings: globalRoleBindings,
+		fleetWorkspaces:    fleetWorkspaces.Cache(),
+		workspaceClient:    fleetWorkspaces,
+		recorder:           recorder,
+	}
+	relatedresource.WatchClusterScoped(ctx, "gorizond-membership-expiry-watch", resolveExpiringBindings, globalRoleBindings, fleetWorkspaces)
+	globalRoleBindings.OnChange(ctx, "gorizond-grb-ttl-controller", metrics.Instrument("gorizond-grb-ttl-controller", h.onChange))
+}
 
-		// Check for the gorizond-ttl label
-		ttlLabel := obj.Labels["gorizond-ttl"]
-		if ttlLabel == "" {
-			return obj, nil
-		}
+// parseTTL reads the gorizond-ttl label, a positive number of seconds.
+func parseTTL(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s label %q: %w", ttlLabel, value, err)
+	}
+	if seconds <= 0 {
+		return 0, fmt.Errorf("invalid %s label %q: must be a positive number of seconds", ttlLabel, value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
 
-		// Parse the TTL value
-		ttlValue, err := strconv.Atoi(ttlLabel)
-		if err != nil {
-			log.Infof("Failed to parse gorizond-ttl label: %v", err)
-			return obj, nil
-		}
+// onChange deletes a binding once its TTL ran out and otherwise requeues it for the moment it
+// does, so expiry does not wait for the next resync. Bindings without a TTL are checked for
+// an expiring membership.
+func (h *globalRoleBindingTTLHandler) onChange(key string, obj *v3.GlobalRoleBinding) (*v3.GlobalRoleBinding, error) {
+	if obj == nil || obj.DeletionTimestamp != nil {
+		return obj, nil
+	}
 
-		// Calculate the expiration time
-		expirationTime := obj.CreationTimestamp.Add(time.Duration(ttlValue) * time.Second)
-		if time.Now().After(expirationTime) {
-			// Delete the GlobalRoleBinding
-			err := globalRoleBindings.Delete(obj.Name, &metav1.DeleteOptions{})
-			if err != nil {
-				log.Infof("Failed to delete GlobalRoleBinding %s: %v", obj.Name, err)
-				return obj, nil
-			}
-			log.Infof("Deleted GlobalRoleBinding %s due to TTL expiration", obj.Name)
-			return nil, nil
+	value, ok := obj.Labels[ttlLabel]
+	if !ok {
+		return h.expireMembership(obj)
+	}
+	ttl, err := parseTTL(value)
+	if err != nil {
+		// retrying does not fix the label, report it and leave the binding alone
+		log.Errorf("GlobalRoleBinding %s: %v", obj.Name, err)
+		if h.recorder != nil {
+			h.recorder.Event(obj, corev1.EventTypeWarning, "InvalidTTL", err.Error())
 		}
+		return obj, nil
+	}
 
+	remaining := time.Until(obj.CreationTimestamp.Add(ttl))
+	if remaining > 0 {
+		h.globalRoleBindings.EnqueueAfter(obj.Name, remaining)
 		return obj, nil
-	})
+	}
+
+	if err := h.globalRoleBindings.Delete(obj.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return obj, fmt.Errorf("failed to delete expired GlobalRoleBinding %s: %w", obj.Name, err)
+	}
+	log.Infof("Deleted GlobalRoleBinding %s due to TTL expiration", obj.Name)
+	return nil, nil
 }
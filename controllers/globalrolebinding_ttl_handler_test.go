@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type fakeGlobalRoleBindingExpirer struct {
+	deleted   []string
+	enqueued  map[string]time.Duration
+	updated   []*managementv3.GlobalRoleBinding
+	deleteErr error
+}
+
+func (f *fakeGlobalRoleBindingExpirer) Update(obj *managementv3.GlobalRoleBinding) (*managementv3.GlobalRoleBinding, error) {
+	f.updated = append(f.updated, obj)
+	return obj, nil
+}
+
+func (f *fakeGlobalRoleBindingExpirer) Delete(name string, options *metav1.DeleteOptions) error {
+	f.deleted = append(f.deleted, name)
+	return f.deleteErr
+}
+
+func (f *fakeGlobalRoleBindingExpirer) EnqueueAfter(name string, duration time.Duration) {
+	f.enqueued[name] = duration
+}
+
+func TestGlobalRoleBindingTTL(t *testing.T) {
+	binding := func(ttl string, age time.Duration) *managementv3.GlobalRoleBinding {
+		return &managementv3.GlobalRoleBinding{ObjectMeta: metav1.ObjectMeta{
+			Name:              "grb-tmp",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			Labels:            map[string]string{ttlLabel: ttl},
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		obj         *managementv3.GlobalRoleBinding
+		deleteErr   error
+		wantDeleted bool
+		wantQueued  bool
+		wantEvent   bool
+		wantErr     bool
+	}{
+		{name: "no ttl", obj: &managementv3.GlobalRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "grb"}}},
+		{name: "pending", obj: binding("3600", time.Minute), wantQueued: true},
+		{name: "expired", obj: binding("60", time.Hour), wantDeleted: true},
+		{name: "delete fails", obj: binding("60", time.Hour), deleteErr: errors.New("boom"), wantDeleted: true, wantErr: true},
+		{name: "not a number", obj: binding("1h", time.Hour), wantEvent: true},
+		{name: "not positive", obj: binding("0", time.Hour), wantEvent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expirer := &fakeGlobalRoleBindingExpirer{enqueued: map[string]time.Duration{}, deleteErr: tt.deleteErr}
+			recorder := record.NewFakeRecorder(10)
+			h := &globalRoleBindingTTLHandler{globalRoleBindings: expirer, recorder: recorder}
+
+			_, err := h.onChange(tt.obj.Name, tt.obj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("onChange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (len(expirer.deleted) > 0) != tt.wantDeleted {
+				t.Errorf("deleted = %v, want deleted %v", expirer.deleted, tt.wantDeleted)
+			}
+			delay, queued := expirer.enqueued[tt.obj.Name]
+			if queued != tt.wantQueued {
+				t.Errorf("enqueued = %v, want queued %v", expirer.enqueued, tt.wantQueued)
+			}
+			if queued && (delay <= 58*time.Minute || delay > time.Hour) {
+				t.Errorf("requeued after %v, want the remaining TTL", delay)
+			}
+			if (len(recorder.Events) > 0) != tt.wantEvent {
+				t.Errorf("events = %d, want event %v", len(recorder.Events), tt.wantEvent)
+			}
+		})
+	}
+}
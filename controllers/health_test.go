@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"testing"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+)
+
+func TestSkipLivenessProbe(t *testing.T) {
+	var keys []string
+	handler := skipLivenessProbe(func(key string, obj *managementv3.FleetWorkspace) (*managementv3.FleetWorkspace, error) {
+		keys = append(keys, key)
+		return obj, nil
+	})
+
+	for _, key := range []string{livenessProbeKey, "workspace-a"} {
+		if _, err := handler(key, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(keys) != 1 || keys[0] != "workspace-a" {
+		t.Fatalf("handler saw %v, want only workspace-a", keys)
+	}
+}
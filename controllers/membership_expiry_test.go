@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"testing"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+type fakeFleetWorkspacePatcher struct {
+	patches map[string][]byte
+	err     error
+}
+
+func (f *fakeFleetWorkspacePatcher) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*managementv3.FleetWorkspace, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.patches[name] = data
+	return &managementv3.FleetWorkspace{}, nil
+}
+
+func TestParseMembershipExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2026-02-01T12:00:00Z", want: time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{value: "168h", want: now.Add(168 * time.Hour)},
+		{value: "7d", want: now.Add(7 * 24 * time.Hour)},
+		{value: "0s", wantErr: true},
+		{value: "-1d", wantErr: true},
+		{value: "next week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseMembershipExpiry(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMembershipExpiry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("parseMembershipExpiry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeMembershipExpiry(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	h := &fleetWorkspaceHandler{recorder: recorder}
+	obj := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Annotations: map[string]string{
+		"gorizond-user.u-contractor.editor":                          "local://u-contractor",
+		membershipExpiryPrefix + "gorizond-user.u-contractor.editor": "7d",
+		"gorizond-user.u-intern.view":                                "local://u-intern",
+		membershipExpiryPrefix + "gorizond-user.u-intern.view":       "2026-02-01T12:00:00Z",
+		"gorizond-user.u-typo.view":                                  "local://u-typo",
+		membershipExpiryPrefix + "gorizond-user.u-typo.view":         "soon",
+		membershipExpiryPrefix + "gorizond-user.u-gone.view":         "2026-02-01T12:00:00Z",
+	}}}
+
+	if !h.normalizeMembershipExpiry(obj) {
+		t.Fatalf("expected annotations to change")
+	}
+	expiry, err := time.Parse(time.RFC3339, obj.Annotations[membershipExpiryPrefix+"gorizond-user.u-contractor.editor"])
+	if err != nil || time.Until(expiry) < 6*24*time.Hour {
+		t.Errorf("duration not stored as a timestamp 7 days ahead, got %q", obj.Annotations[membershipExpiryPrefix+"gorizond-user.u-contractor.editor"])
+	}
+	if obj.Annotations[membershipExpiryPrefix+"gorizond-user.u-intern.view"] != "2026-02-01T12:00:00Z" {
+		t.Errorf("timestamp must be kept as is")
+	}
+	if obj.Annotations[membershipExpiryPrefix+"gorizond-user.u-typo.view"] != "soon" || len(recorder.Events) != 1 {
+		t.Errorf("invalid expiry must be kept and reported, got %d events", len(recorder.Events))
+	}
+	if _, ok := obj.Annotations[membershipExpiryPrefix+"gorizond-user.u-gone.view"]; ok {
+		t.Errorf("expiry of a removed membership must be dropped")
+	}
+}
+
+func TestExpireMembership(t *testing.T) {
+	const member = "gorizond-user.u-contractor.editor"
+	binding := &managementv3.GlobalRoleBinding{ObjectMeta: metav1.ObjectMeta{
+		Name:        "gorizond-editor-u-contractor-workspace-a",
+		Labels:      map[string]string{"fleet": "workspace-a"},
+		Annotations: map[string]string{"gorizond-binding": member},
+	}}
+	workspace := func(expiry time.Time) *managementv3.FleetWorkspace {
+		return &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", ResourceVersion: "42", Annotations: map[string]string{
+			member:                          "local://u-contractor",
+			membershipExpiryPrefix + member: expiry.UTC().Format(time.RFC3339),
+		}}}
+	}
+
+	tests := []struct {
+		name        string
+		expiry      time.Time
+		warned      bool
+		patchErr    error
+		wantQueued  time.Duration
+		wantWarned  bool
+		wantExpired bool
+		wantEvents  int
+	}{
+		{name: "far ahead", expiry: time.Now().Add(7 * 24 * time.Hour), wantQueued: 6 * 24 * time.Hour},
+		{name: "warning", expiry: time.Now().Add(time.Hour), wantQueued: time.Hour, wantWarned: true, wantEvents: 1},
+		{name: "already warned", expiry: time.Now().Add(time.Hour), warned: true, wantQueued: time.Hour},
+		{name: "expired", expiry: time.Now().Add(-time.Minute), wantExpired: true, wantEvents: 1},
+		{
+			name:       "last admin kept by the webhook",
+			expiry:     time.Now().Add(-time.Minute),
+			patchErr:   errors.NewForbidden(schema.GroupResource{Group: "management.cattle.io", Resource: "fleetworkspaces"}, "workspace-a", goerrors.New("the update removes the last admin of the workspace")),
+			wantEvents: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace(tt.expiry)
+			obj := binding.DeepCopy()
+			if tt.warned {
+				obj.Annotations[expiryWarnedAnnotation] = ws.Annotations[membershipExpiryPrefix+member]
+			}
+			expirer := &fakeGlobalRoleBindingExpirer{enqueued: map[string]time.Duration{}}
+			patcher := &fakeFleetWorkspacePatcher{patches: map[string][]byte{}, err: tt.patchErr}
+			recorder := record.NewFakeRecorder(10)
+			h := &globalRoleBindingTTLHandler{
+				globalRoleBindings: expirer,
+				fleetWorkspaces:    newFakeCache(ws),
+				workspaceClient:    patcher,
+				recorder:           recorder,
+			}
+
+			if _, err := h.onChange(obj.Name, obj); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if delay := expirer.enqueued[obj.Name]; tt.wantQueued > 0 && (delay > tt.wantQueued || delay < tt.wantQueued-time.Minute) {
+				t.Errorf("requeued after %v, want about %v", delay, tt.wantQueued)
+			}
+			if (len(expirer.updated) > 0) != tt.wantWarned {
+				t.Errorf("warned = %v, want %v", len(expirer.updated) > 0, tt.wantWarned)
+			}
+			if len(recorder.Events) != tt.wantEvents {
+				t.Errorf("events = %d, want %d", len(recorder.Events), tt.wantEvents)
+			}
+			if (len(expirer.deleted) > 0) != tt.wantExpired {
+				t.Errorf("deleted = %v, want expired %v", expirer.deleted, tt.wantExpired)
+			}
+			if !tt.wantExpired {
+				return
+			}
+			var patch struct {
+				Metadata struct {
+					ResourceVersion string             `json:"resourceVersion"`
+					Annotations     map[string]*string `json:"annotations"`
+				} `json:"metadata"`
+			}
+			if err := json.Unmarshal(patcher.patches["workspace-a"], &patch); err != nil {
+				t.Fatalf("invalid patch %s: %v", patcher.patches["workspace-a"], err)
+			}
+			if patch.Metadata.ResourceVersion != "42" || len(patch.Metadata.Annotations) != 2 {
+				t.Errorf("patch = %s, want both annotations removed at resource version 42", patcher.patches["workspace-a"])
+			}
+		})
+	}
+}
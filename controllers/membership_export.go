@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Sources of an exported membership. Only user, group and principal memberships are
+// annotations an import manages, WorkspaceMembership objects are exported for the record.
+const (
+	MembershipSourceUser       = "user"
+	MembershipSourceGroup      = "group"
+	MembershipSourcePrincipal  = "principal"
+	MembershipSourceMembership = "membership"
+)
+
+var membershipCSVHeader = []string{"workspace", "userId", "principal", "role", "source", "id", "expires"}
+
+// MembershipRecord is one effective membership of a workspace.
+type MembershipRecord struct {
+	Workspace string `json:"workspace"`
+	UserID    string `json:"userId,omitempty"`
+	Principal string `json:"principal,omitempty"`
+	Role      string `json:"role"`
+	Source    string `json:"source"`
+	// ID is the id segment of a group or principal annotation key. Existing keys do not
+	// always use the principalKeyID form, without it an import would rename them.
+	ID string `json:"id,omitempty"`
+	// Expires is the gorizond-expires. annotation of the membership.
+	Expires string `json:"expires,omitempty"`
+}
+
+// MembershipDocument is the YAML form of an export, the CSV form has one record per row.
+type MembershipDocument struct {
+	Memberships []MembershipRecord `json:"memberships"`
+}
+
+// ExportMemberships lists the effective memberships of a workspace: its membership
+// annotations and the WorkspaceMemberships naming it.
+func ExportMemberships(ws *managementv3.FleetWorkspace, memberships []workspacev1.WorkspaceMembership) []MembershipRecord {
+	var records []MembershipRecord
+	for k, v := range ws.Annotations {
+		prefix := membershipPrefix(k)
+		if prefix == "" {
+			continue
+		}
+		id, role, err := parseMembershipKey(prefix, k)
+		if err != nil {
+			continue
+		}
+		record := MembershipRecord{Workspace: ws.Name, Principal: v, Role: role, Expires: ws.Annotations[membershipExpiryPrefix+k]}
+		switch prefix {
+		case "gorizond-user.":
+			record.UserID, record.Source = id, MembershipSourceUser
+		case "gorizond-group.":
+			record.ID, record.Source = id, MembershipSourceGroup
+		default:
+			record.ID, record.Source = id, MembershipSourcePrincipal
+		}
+		records = append(records, record)
+	}
+	for _, membership := range memberships {
+		if membership.Spec.Workspace != ws.Name || membership.DeletionTimestamp != nil {
+			continue
+		}
+		record := MembershipRecord{Workspace: ws.Name, Role: membership.Spec.Role, Source: MembershipSourceMembership}
+		if membership.Spec.Subject.Kind == workspacev1.SubjectKindUser {
+			record.UserID = membership.Spec.Subject.Name
+		} else {
+			record.Principal = membership.Spec.Subject.Name
+		}
+		records = append(records, record)
+	}
+	sortMembershipRecords(records)
+	return records
+}
+
+func sortMembershipRecords(records []MembershipRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Workspace != b.Workspace {
+			return a.Workspace < b.Workspace
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.Principal < b.Principal
+	})
+}
+
+// EncodeMemberships writes records as yaml or csv.
+func EncodeMemberships(w io.Writer, records []MembershipRecord, format string) error {
+	switch format {
+	case "yaml":
+		data, err := yaml.Marshal(MembershipDocument{Memberships: records})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case "csv":
+		out := csv.NewWriter(w)
+		if err := out.Write(membershipCSVHeader); err != nil {
+			return err
+		}
+		for _, r := range records {
+			if err := out.Write([]string{r.Workspace, r.UserID, r.Principal, r.Role, r.Source, r.ID, r.Expires}); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		return out.Error()
+	}
+	return fmt.Errorf("unknown format %q, expected yaml or csv", format)
+}
+
+// DecodeMemberships reads records written by EncodeMemberships. CSV columns are matched by
+// their header so they may come in any order.
+func DecodeMemberships(r io.Reader, format string) ([]MembershipRecord, error) {
+	switch format {
+	case "yaml":
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		var doc MembershipDocument
+		if err := yaml.UnmarshalStrict(data, &doc); err != nil {
+			return nil, err
+		}
+		return doc.Memberships, nil
+	case "csv":
+		rows, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, nil
+		}
+		columns := map[string]int{}
+		for i, name := range rows[0] {
+			columns[strings.TrimSpace(name)] = i
+		}
+		for _, name := range []string{"workspace", "role", "source"} {
+			if _, ok := columns[name]; !ok {
+				return nil, fmt.Errorf("csv header has no %q column", name)
+			}
+		}
+		cell := func(row []string, name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		records := make([]MembershipRecord, 0, len(rows)-1)
+		for _, row := range rows[1:] {
+			records = append(records, MembershipRecord{
+				Workspace: cell(row, "workspace"),
+				UserID:    cell(row, "userId"),
+				Principal: cell(row, "principal"),
+				Role:      cell(row, "role"),
+				Source:    cell(row, "source"),
+				ID:        cell(row, "id"),
+				Expires:   cell(row, "expires"),
+			})
+		}
+		return records, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, expected yaml or csv", format)
+}
+
+// MembershipChange is one membership annotation an import adds, removes or changes.
+type MembershipChange struct {
+	Key string
+	Old string
+	New string
+}
+
+// MembershipPlan is what importing a document changes on one workspace.
+type MembershipPlan struct {
+	Workspace       string
+	ResourceVersion string
+	Changes         []MembershipChange
+}
+
+// PlanMembershipImport compares the user, group and principal records of one workspace with
+// its membership and expiry annotations. The records are the desired state: missing
+// annotations are added, changed principals and expiries updated and annotations without a
+// record removed. A group or principal record without an id keeps the key of an existing
+// annotation granting the role to the same principal. Expiry durations are planned as
+// timestamps counted from now. otherAdmins counts the admin WorkspaceMemberships, an import
+// leaving the workspace without any admin, or letting every admin expire, is refused.
+func PlanMembershipImport(ws *managementv3.FleetWorkspace, records []MembershipRecord, catalog *RoleCatalog, otherAdmins int) (MembershipPlan, error) {
+	plan := MembershipPlan{Workspace: ws.Name, ResourceVersion: ws.ResourceVersion}
+
+	desired := map[string]string{}
+	expiries := map[string]string{}
+	now := time.Now()
+	for i, r := range records {
+		if r.Workspace != ws.Name || r.Source == MembershipSourceMembership {
+			continue
+		}
+		if _, ok := catalog.Role(r.Role); !ok {
+			return plan, fmt.Errorf("record %d: role %q is not in the role catalog", i+1, r.Role)
+		}
+		var key, value string
+		switch r.Source {
+		case MembershipSourceUser:
+			if r.UserID == "" {
+				return plan, fmt.Errorf("record %d: a user membership needs a userId", i+1)
+			}
+			key, value = "gorizond-user."+r.UserID+"."+r.Role, r.Principal
+			if value == "" {
+				value = "local://" + r.UserID
+			}
+		case MembershipSourceGroup, MembershipSourcePrincipal:
+			if r.Principal == "" {
+				return plan, fmt.Errorf("record %d: a %s membership needs a principal", i+1, r.Source)
+			}
+			prefix := "gorizond-" + r.Source + "."
+			id := r.ID
+			if id == "" {
+				id = existingKeyID(ws, prefix, r.Principal, r.Role)
+			}
+			key, value = prefix+id+"."+r.Role, r.Principal
+		default:
+			return plan, fmt.Errorf("record %d: unknown source %q", i+1, r.Source)
+		}
+		if _, _, err := parseMembershipKey(membershipPrefix(key), key); err != nil {
+			return plan, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		if previous, ok := desired[key]; ok && previous != value {
+			return plan, fmt.Errorf("record %d: %s is listed with %s and %s", i+1, key, previous, value)
+		}
+		if r.Expires != "" {
+			expiry, err := parseMembershipExpiry(r.Expires, now)
+			if err != nil {
+				return plan, fmt.Errorf("record %d: invalid expiry %q: %w", i+1, r.Expires, err)
+			}
+			// durations are planned as the timestamp the controller would normalize them to,
+			// so they compare with the stored annotations
+			value := r.Expires
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				value = expiry.UTC().Format(time.RFC3339)
+			}
+			expiries[membershipExpiryPrefix+key] = value
+		}
+		desired[key] = value
+	}
+
+	for key, value := range desired {
+		if old, ok := ws.Annotations[key]; !ok || old != value {
+			plan.Changes = append(plan.Changes, MembershipChange{Key: key, Old: old, New: value})
+		}
+	}
+	for key, value := range expiries {
+		if old := ws.Annotations[key]; old != value {
+			plan.Changes = append(plan.Changes, MembershipChange{Key: key, Old: old, New: value})
+		}
+	}
+	for key, value := range ws.Annotations {
+		wanted := desired
+		if strings.HasPrefix(key, membershipExpiryPrefix) {
+			wanted = expiries
+		} else if membershipPrefix(key) == "" {
+			continue
+		}
+		if _, ok := wanted[key]; !ok {
+			plan.Changes = append(plan.Changes, MembershipChange{Key: key, Old: value})
+		}
+	}
+	sort.Slice(plan.Changes, func(i, j int) bool { return plan.Changes[i].Key < plan.Changes[j].Key })
+
+	if len(plan.Changes) == 0 || otherAdmins > 0 {
+		return plan, nil
+	}
+	if annotationAdmins(desired) == 0 {
+		return plan, fmt.Errorf("workspace %s would be left without an admin", ws.Name)
+	}
+	planned := maps.Clone(desired)
+	maps.Copy(planned, expiries)
+	if permanentAdmins(ws.Annotations) > 0 && permanentAdmins(planned) == 0 {
+		return plan, fmt.Errorf("every admin of workspace %s would expire, keep one admin without an expiry", ws.Name)
+	}
+	return plan, nil
+}
+
+// existingKeyID returns the id of an annotation granting role to principal with prefix, the
+// principalKeyID form when there is none.
+func existingKeyID(ws *managementv3.FleetWorkspace, prefix, principal, role string) string {
+	for key, value := range ws.Annotations {
+		if value != principal || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if id, keyRole, err := parseMembershipKey(prefix, key); err == nil && keyRole == role {
+			return id
+		}
+	}
+	return principalKeyID(principal)
+}
+
+// WriteDiff prints the plan, + for added, - for removed and ~ for changed memberships.
+func (p MembershipPlan) WriteDiff(w io.Writer) error {
+	if len(p.Changes) == 0 {
+		_, err := fmt.Fprintf(w, "%s: no changes\n", p.Workspace)
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%s:\n", p.Workspace); err != nil {
+		return err
+	}
+	for _, c := range p.Changes {
+		var err error
+		switch {
+		case c.Old == "":
+			_, err = fmt.Fprintf(w, "+ %s: %s\n", c.Key, c.New)
+		case c.New == "":
+			_, err = fmt.Fprintf(w, "- %s: %s\n", c.Key, c.Old)
+		default:
+			_, err = fmt.Fprintf(w, "~ %s: %s -> %s\n", c.Key, c.Old, c.New)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Patch returns the merge patch applying the plan. It carries the resourceVersion the plan
+// was made from, so a workspace changed in the meantime is not overwritten.
+func (p MembershipPlan) Patch() ([]byte, error) {
+	annotations := map[string]interface{}{}
+	for _, c := range p.Changes {
+		if c.New == "" {
+			annotations[c.Key] = nil
+			continue
+		}
+		annotations[c.Key] = c.New
+	}
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": p.ResourceVersion,
+			"annotations":     annotations,
+		},
+	})
+}
+
+// PlanMembershipImports plans the import of records into every workspace they name. All
+// workspaces are planned before any is patched, so a bad record does not leave half an
+// import behind.
+func PlanMembershipImports(records []MembershipRecord, fleetWorkspaces fleetWorkspaceGetter, memberships membershipLister, configMaps configMapGetter) ([]MembershipPlan, error) {
+	catalog, err := loadRoleCatalog(configMaps)
+	if err != nil {
+		return nil, err
+	}
+	var plans []MembershipPlan
+	seen := map[string]bool{}
+	for _, record := range records {
+		if seen[record.Workspace] {
+			continue
+		}
+		seen[record.Workspace] = true
+		ws, err := fleetWorkspaces.Get(record.Workspace, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		otherAdmins, err := membershipAdmins(memberships, ws.Name)
+		if err != nil {
+			return nil, err
+		}
+		plan, err := PlanMembershipImport(ws, records, catalog, otherAdmins)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
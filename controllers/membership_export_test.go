@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func exportWorkspace() *managementv3.FleetWorkspace {
+	return &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{
+		Name:            "workspace-a",
+		ResourceVersion: "7",
+		Annotations: map[string]string{
+			"field.cattle.io/creatorId":                           "u-admin",
+			"gorizond-user.u-admin.admin":                         "github_user://1",
+			"gorizond-user.u-dev.editor":                          "local://u-dev",
+			"gorizond-group.42.view":                              "github_org://42",
+			"gorizond-principal.9.view":                           "github_user://9",
+			membershipExpiryPrefix + "gorizond-user.u-dev.editor": "2030-01-01T00:00:00Z",
+		},
+	}}
+}
+
+func TestExportMemberships(t *testing.T) {
+	memberships := []workspacev1.WorkspaceMembership{
+		{Spec: workspacev1.WorkspaceMembershipSpec{Workspace: "workspace-a", Role: "view", Subject: workspacev1.Subject{Kind: workspacev1.SubjectKindUser, Name: "u-ops"}}},
+		{Spec: workspacev1.WorkspaceMembershipSpec{Workspace: "workspace-b", Role: "view", Subject: workspacev1.Subject{Kind: workspacev1.SubjectKindUser, Name: "u-other"}}},
+	}
+
+	got := ExportMemberships(exportWorkspace(), memberships)
+	want := []MembershipRecord{
+		{Workspace: "workspace-a", Principal: "github_org://42", Role: "view", Source: MembershipSourceGroup, ID: "42"},
+		{Workspace: "workspace-a", UserID: "u-ops", Role: "view", Source: MembershipSourceMembership},
+		{Workspace: "workspace-a", Principal: "github_user://9", Role: "view", Source: MembershipSourcePrincipal, ID: "9"},
+		{Workspace: "workspace-a", UserID: "u-admin", Principal: "github_user://1", Role: "admin", Source: MembershipSourceUser},
+		{Workspace: "workspace-a", UserID: "u-dev", Principal: "local://u-dev", Role: "editor", Source: MembershipSourceUser, Expires: "2030-01-01T00:00:00Z"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExportMemberships() = %+v, want %+v", got, want)
+	}
+
+	for _, format := range []string{"yaml", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeMemberships(&buf, got, format); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			decoded, err := DecodeMemberships(&buf, format)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, got) {
+				t.Fatalf("round trip = %+v, want %+v", decoded, got)
+			}
+		})
+	}
+}
+
+func TestDecodeMembershipsCSVColumnOrder(t *testing.T) {
+	in := "role,source,workspace,userId\nview,user,workspace-a,u-dev\n"
+	got, err := DecodeMemberships(strings.NewReader(in), "csv")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []MembershipRecord{{Workspace: "workspace-a", UserID: "u-dev", Role: "view", Source: MembershipSourceUser}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DecodeMemberships() = %+v, want %+v", got, want)
+	}
+	if _, err := DecodeMemberships(strings.NewReader("userId,role\nu-dev,view\n"), "csv"); err == nil {
+		t.Fatal("expected an error for a header without workspace")
+	}
+}
+
+func TestPlanMembershipImport(t *testing.T) {
+	exported := ExportMemberships(exportWorkspace(), nil)
+	user := func(id, role string) MembershipRecord {
+		return MembershipRecord{Workspace: "workspace-a", UserID: id, Role: role, Source: MembershipSourceUser}
+	}
+
+	tests := []struct {
+		name        string
+		records     []MembershipRecord
+		otherAdmins int
+		want        []MembershipChange
+		wantErr     string
+	}{
+		{name: "unchanged export", records: exported},
+		{
+			name: "declarative",
+			records: []MembershipRecord{
+				exported[2],
+				user("u-dev", "view"),
+				{Workspace: "workspace-a", Principal: "github_org://42", Role: "editor", Source: MembershipSourceGroup},
+				{Workspace: "workspace-b", UserID: "u-elsewhere", Role: "view", Source: MembershipSourceUser},
+				{Workspace: "workspace-a", UserID: "u-ops", Role: "view", Source: MembershipSourceMembership},
+			},
+			want: []MembershipChange{
+				{Key: membershipExpiryPrefix + "gorizond-user.u-dev.editor", Old: "2030-01-01T00:00:00Z"},
+				{Key: "gorizond-group.42.editor", New: "github_org://42"},
+				{Key: "gorizond-group.42.view", Old: "github_org://42"},
+				{Key: "gorizond-principal.9.view", Old: "github_user://9"},
+				{Key: "gorizond-user.u-dev.editor", Old: "local://u-dev"},
+				{Key: "gorizond-user.u-dev.view", New: "local://u-dev"},
+			},
+		},
+		{
+			name:    "changed principal",
+			records: append(append([]MembershipRecord{}, exported[:2]...), MembershipRecord{Workspace: "workspace-a", UserID: "u-admin", Principal: "local://u-admin", Role: "admin", Source: MembershipSourceUser}, exported[3]),
+			want:    []MembershipChange{{Key: "gorizond-user.u-admin.admin", Old: "github_user://1", New: "local://u-admin"}},
+		},
+		{name: "last admin", records: []MembershipRecord{user("u-dev", "editor")}, wantErr: "without an admin"},
+		{
+			name:        "admin membership left",
+			records:     []MembershipRecord{exported[3]},
+			otherAdmins: 1,
+			want: []MembershipChange{
+				{Key: "gorizond-group.42.view", Old: "github_org://42"},
+				{Key: "gorizond-principal.9.view", Old: "github_user://9"},
+				{Key: "gorizond-user.u-admin.admin", Old: "github_user://1"},
+			},
+		},
+		{
+			name:    "changed expiry",
+			records: append(append([]MembershipRecord{}, exported[:4]...), MembershipRecord{Workspace: "workspace-a", UserID: "u-dev", Principal: "local://u-dev", Role: "editor", Source: MembershipSourceUser, Expires: "2031-01-01T00:00:00Z"}),
+			want:    []MembershipChange{{Key: membershipExpiryPrefix + "gorizond-user.u-dev.editor", Old: "2030-01-01T00:00:00Z", New: "2031-01-01T00:00:00Z"}},
+		},
+		{
+			name:    "expiring admins",
+			records: []MembershipRecord{{Workspace: "workspace-a", UserID: "u-admin", Principal: "github_user://1", Role: "admin", Source: MembershipSourceUser, Expires: "2030-01-01T00:00:00Z"}},
+			wantErr: "would expire",
+		},
+		{
+			name:        "expiring admins with an admin membership",
+			records:     []MembershipRecord{{Workspace: "workspace-a", UserID: "u-admin", Principal: "github_user://1", Role: "admin", Source: MembershipSourceUser, Expires: "2030-01-01T00:00:00Z"}},
+			otherAdmins: 1,
+			want: []MembershipChange{
+				{Key: membershipExpiryPrefix + "gorizond-user.u-admin.admin", New: "2030-01-01T00:00:00Z"},
+				{Key: membershipExpiryPrefix + "gorizond-user.u-dev.editor", Old: "2030-01-01T00:00:00Z"},
+				{Key: "gorizond-group.42.view", Old: "github_org://42"},
+				{Key: "gorizond-principal.9.view", Old: "github_user://9"},
+				{Key: "gorizond-user.u-dev.editor", Old: "local://u-dev"},
+			},
+		},
+		{name: "invalid expiry", records: []MembershipRecord{exported[3], {Workspace: "workspace-a", UserID: "u-dev", Role: "view", Source: MembershipSourceUser, Expires: "soon"}}, wantErr: "invalid expiry"},
+		{name: "unknown role", records: []MembershipRecord{exported[2], user("u-dev", "owner")}, wantErr: "role catalog"},
+		{name: "unknown source", records: []MembershipRecord{{Workspace: "workspace-a", UserID: "u-dev", Role: "view", Source: "team"}}, wantErr: "unknown source"},
+		{name: "group without principal", records: []MembershipRecord{{Workspace: "workspace-a", Role: "view", Source: MembershipSourceGroup}}, wantErr: "needs a principal"},
+		{
+			name:    "conflicting principals",
+			records: []MembershipRecord{exported[2], {Workspace: "workspace-a", UserID: "u-admin", Principal: "local://u-admin", Role: "admin", Source: MembershipSourceUser}},
+			wantErr: "listed with",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := PlanMembershipImport(exportWorkspace(), tt.records, defaultRoleCatalog(), tt.otherAdmins)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(plan.Changes, tt.want) {
+				t.Fatalf("changes = %+v, want %+v", plan.Changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanMembershipImportExpiryDuration(t *testing.T) {
+	records := ExportMemberships(exportWorkspace(), nil)
+	for i := range records {
+		if records[i].Expires != "" {
+			records[i].Expires = "7d"
+		}
+	}
+	before := time.Now()
+	plan, err := PlanMembershipImport(exportWorkspace(), records, defaultRoleCatalog(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Key != membershipExpiryPrefix+"gorizond-user.u-dev.editor" {
+		t.Fatalf("expected only the expiry to change, got %+v", plan.Changes)
+	}
+	expiry, err := time.Parse(time.RFC3339, plan.Changes[0].New)
+	if err != nil {
+		t.Fatalf("expected the duration as a timestamp, got %q", plan.Changes[0].New)
+	}
+	if want := before.Add(7 * 24 * time.Hour).Truncate(time.Second); expiry.Before(want) || expiry.After(want.Add(time.Minute)) {
+		t.Fatalf("expected an expiry 7 days from now, got %s", expiry)
+	}
+
+	// the exported timestamp plans nothing
+	ws := exportWorkspace()
+	ws.Annotations[plan.Changes[0].Key] = plan.Changes[0].New
+	records = ExportMemberships(ws, nil)
+	if plan, err := PlanMembershipImport(ws, records, defaultRoleCatalog(), 0); err != nil || len(plan.Changes) != 0 {
+		t.Fatalf("expected the re-import to change nothing, got %+v %v", plan.Changes, err)
+	}
+}
+
+func TestMembershipPlanDiffAndPatch(t *testing.T) {
+	plan := MembershipPlan{Workspace: "workspace-a", ResourceVersion: "7", Changes: []MembershipChange{
+		{Key: "gorizond-group.42.editor", New: "github_org://42"},
+		{Key: "gorizond-user.u-admin.admin", Old: "github_user://1", New: "local://u-admin"},
+		{Key: "gorizond-user.u-dev.editor", Old: "local://u-dev"},
+	}}
+
+	var diff bytes.Buffer
+	if err := plan.WriteDiff(&diff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `workspace-a:
++ gorizond-group.42.editor: github_org://42
+~ gorizond-user.u-admin.admin: github_user://1 -> local://u-admin
+- gorizond-user.u-dev.editor: local://u-dev
+`
+	if diff.String() != want {
+		t.Fatalf("diff = %q, want %q", diff.String(), want)
+	}
+
+	data, err := plan.Patch()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var patch struct {
+		Metadata struct {
+			ResourceVersion string             `json:"resourceVersion"`
+			Annotations     map[string]*string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		t.Fatalf("invalid patch %s: %v", data, err)
+	}
+	if patch.Metadata.ResourceVersion != "7" {
+		t.Errorf("patch = %s, want resourceVersion 7", data)
+	}
+	annotations := patch.Metadata.Annotations
+	if v := annotations["gorizond-user.u-admin.admin"]; v == nil || *v != "local://u-admin" {
+		t.Errorf("patch = %s, want the changed principal", data)
+	}
+	if v, ok := annotations["gorizond-user.u-dev.editor"]; !ok || v != nil {
+		t.Errorf("patch = %s, want gorizond-user.u-dev.editor removed", data)
+	}
+	if len(annotations) != len(plan.Changes) {
+		t.Errorf("patch = %s, want only the planned changes", data)
+	}
+
+	diff.Reset()
+	if err := (MembershipPlan{Workspace: "workspace-a"}).WriteDiff(&diff); err != nil || diff.String() != "workspace-a: no changes\n" {
+		t.Fatalf("empty diff = %q, %v", diff.String(), err)
+	}
+}
+
+func TestMembershipExportImportRoundTrip(t *testing.T) {
+	ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{
+		Name:            "workspace-a",
+		ResourceVersion: "3",
+		Annotations: map[string]string{
+			"gorizond-user.u-admin.admin":                         "local://u-admin",
+			"gorizond-group.devs.editor":                          "github_org://123",
+			"gorizond-principal.alice.view":                       "github_user://42",
+			membershipExpiryPrefix + "gorizond-group.devs.editor": "2030-01-01T00:00:00Z",
+		},
+	}}
+
+	for _, format := range []string{"yaml", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeMemberships(&buf, ExportMemberships(ws, nil), format); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			records, err := DecodeMemberships(&buf, format)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			plan, err := PlanMembershipImport(ws, records, defaultRoleCatalog(), 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(plan.Changes) != 0 {
+				t.Fatalf("changes = %+v, want none", plan.Changes)
+			}
+		})
+	}
+
+	t.Run("without ids", func(t *testing.T) {
+		records := ExportMemberships(ws, nil)
+		for i := range records {
+			records[i].ID = ""
+		}
+		plan, err := PlanMembershipImport(ws, records, defaultRoleCatalog(), 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(plan.Changes) != 0 {
+			t.Fatalf("changes = %+v, want none", plan.Changes)
+		}
+	})
+}
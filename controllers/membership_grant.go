@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	v3 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	workspace "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io"
+	workspacecontrollers "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io/v1"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// errNotWorkspaceAdmin is returned by grant when the grantor does not administer the
+// workspace, retrying does not help.
+var errNotWorkspaceAdmin = goerrors.New("not an admin of the workspace")
+
+// membershipGranter adds gorizond-user. membership annotations to workspaces for the
+// controllers granting access on an admin's behalf. The workspace handler then binds them
+// through createGlobalRoleBinding like any other member.
+type membershipGranter struct {
+	fleetWorkspaces v3.FleetWorkspaceCache
+	workspaceClient fleetWorkspacePatcher
+	users           v3.UserCache
+	memberships     workspacecontrollers.WorkspaceMembershipCache
+	userAttributes  userAttributeGetter
+	configMaps      configMapReader
+}
+
+func newMembershipGranter(mgmt *management.Factory, ws *workspace.Factory, configMaps corecontrollers.ConfigMapCache) *membershipGranter {
+	fleetWorkspaces := mgmt.Management().V3().FleetWorkspace()
+	return &membershipGranter{
+		fleetWorkspaces: fleetWorkspaces.Cache(),
+		workspaceClient: fleetWorkspaces,
+		users:           mgmt.Management().V3().User().Cache(),
+		memberships:     ws.Workspace().V1().WorkspaceMembership().Cache(),
+		userAttributes:  mgmt.Management().V3().UserAttribute().Cache(),
+		configMaps:      configMaps,
+	}
+}
+
+// grantFor grants like grant on behalf of grantor, who must administer the workspace at
+// that moment. The objects asking for a grant are writable by their creators and prove
+// nothing on their own.
+func (g *membershipGranter) grantFor(grantor, workspace, userID, role string, duration *metav1.Duration, now time.Time) error {
+	ws, err := g.fleetWorkspace(workspace)
+	if err != nil {
+		return err
+	}
+	admin, err := g.isAdmin(ws, grantor)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("%q is %w %s", grantor, errNotWorkspaceAdmin, ws.Name)
+	}
+	return g.grant(workspace, userID, role, duration, now)
+}
+
+func (g *membershipGranter) fleetWorkspace(name string) (*managementv3.FleetWorkspace, error) {
+	ws, err := g.fleetWorkspaces.Get(name)
+	if errors.IsNotFound(err) {
+		return nil, fmt.Errorf("workspace %q not found", name)
+	}
+	return ws, err
+}
+
+// grant gives userID the role in workspace, until now plus duration when duration is set. A
+// user who already holds the role keeps it unchanged, so a duration never shortens an
+// existing membership.
+func (g *membershipGranter) grant(workspace, userID, role string, duration *metav1.Duration, now time.Time) error {
+	ws, err := g.fleetWorkspace(workspace)
+	if err != nil {
+		return err
+	}
+	catalog, err := roleCatalog.read(g.configMaps)
+	if err != nil {
+		return err
+	}
+	if _, ok := catalog.Role(role); !ok {
+		return fmt.Errorf("role %q is not in the role catalog", role)
+	}
+
+	if _, ok := ws.Annotations["gorizond-user."+userID+"."+role]; ok {
+		return nil
+	}
+	key, value, err := userMembershipAnnotation(g.users, userID, role)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]interface{}{key: value}
+	if duration != nil {
+		annotations[membershipExpiryPrefix+key] = now.Add(duration.Duration).UTC().Format(time.RFC3339)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := g.workspaceClient.Patch(ws.Name, types.MergePatchType, patch); err != nil {
+		return fmt.Errorf("failed to add %s to workspace %s: %w", key, ws.Name, err)
+	}
+	return nil
+}
+
+// isAdmin reports whether an admin annotation or WorkspaceMembership of the workspace names
+// userID or one of its groups.
+func (g *membershipGranter) isAdmin(ws *managementv3.FleetWorkspace, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+	var memberships []workspacev1.WorkspaceMembership
+	if g.memberships != nil {
+		list, err := g.memberships.List(labels.Everything())
+		if err != nil {
+			return false, err
+		}
+		for _, membership := range list {
+			memberships = append(memberships, *membership)
+		}
+	}
+	return isWorkspaceAdmin(ws, memberships, userID, userGroups(g.userAttributes, userID)), nil
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// AuthProvider describes the principal ID schemes a Rancher auth provider uses,
+// e.g. `keycloak_user://` and `keycloak_group://`.
+type AuthProvider struct {
+	Name         string
+	UserSchemes  []string
+	GroupSchemes []string
+}
+
+var (
+	authProvidersMu sync.RWMutex
+	// principalSchemes maps a principal scheme to whether it is a group scheme.
+	principalSchemes = map[string]bool{}
+)
+
+func init() {
+	for _, provider := range []AuthProvider{
+		{Name: "local", UserSchemes: []string{"local"}, GroupSchemes: []string{"local_group"}},
+		{Name: "system", UserSchemes: []string{"system"}},
+		{Name: "github", UserSchemes: []string{"github_user"}, GroupSchemes: []string{"github_org", "github_team"}},
+		{Name: "githubapp", UserSchemes: []string{"githubapp_user"}, GroupSchemes: []string{"githubapp_org", "githubapp_team"}},
+		{Name: "googleoauth", UserSchemes: []string{"googleoauth_user"}, GroupSchemes: []string{"googleoauth_group"}},
+		{Name: "azuread", UserSchemes: []string{"azuread_user"}, GroupSchemes: []string{"azuread_group"}},
+		{Name: "activedirectory", UserSchemes: []string{"activedirectory_user"}, GroupSchemes: []string{"activedirectory_group"}},
+		{Name: "openldap", UserSchemes: []string{"openldap_user"}, GroupSchemes: []string{"openldap_group"}},
+		{Name: "freeipa", UserSchemes: []string{"freeipa_user"}, GroupSchemes: []string{"freeipa_group"}},
+		{Name: "ping", UserSchemes: []string{"ping_user"}, GroupSchemes: []string{"ping_group"}},
+		{Name: "adfs", UserSchemes: []string{"adfs_user"}, GroupSchemes: []string{"adfs_group"}},
+		{Name: "keycloak", UserSchemes: []string{"keycloak_user"}, GroupSchemes: []string{"keycloak_group"}},
+		{Name: "okta", UserSchemes: []string{"okta_user"}, GroupSchemes: []string{"okta_group"}},
+		{Name: "shibboleth", UserSchemes: []string{"shibboleth_user"}, GroupSchemes: []string{"shibboleth_group"}},
+		{Name: "genericoidc", UserSchemes: []string{"genericoidc_user"}, GroupSchemes: []string{"genericoidc_group"}},
+		{Name: "keycloakoidc", UserSchemes: []string{"keycloakoidc_user"}, GroupSchemes: []string{"keycloakoidc_group"}},
+		{Name: "cognito", UserSchemes: []string{"cognito_user"}, GroupSchemes: []string{"cognito_group"}},
+	} {
+		RegisterAuthProvider(provider)
+	}
+}
+
+// RegisterAuthProvider adds or overrides the principal schemes of an auth provider.
+func RegisterAuthProvider(provider AuthProvider) {
+	authProvidersMu.Lock()
+	defer authProvidersMu.Unlock()
+	for _, scheme := range provider.UserSchemes {
+		principalSchemes[scheme] = false
+	}
+	for _, scheme := range provider.GroupSchemes {
+		principalSchemes[scheme] = true
+	}
+}
+
+// principalScheme returns the scheme of a principal ID, `keycloak_group` for `keycloak_group://devs`.
+func principalScheme(principalID string) string {
+	scheme, _, found := strings.Cut(principalID, "://")
+	if !found {
+		return ""
+	}
+	return scheme
+}
+
+// principalTypeFromAPI reports whether the principalType returned by /v3/principals
+// is trusted over the scheme registry.
+func principalTypeFromAPI() bool {
+	return os.Getenv("PRINCIPAL_TYPE_FROM_API") == "true"
+}
+
+// isGroupPrincipal decides whether a principal is a group. apiType is the principalType
+// reported by Rancher, empty when it was not looked up. Unknown schemes fall back to apiType.
+func isGroupPrincipal(principalID, apiType string) bool {
+	if principalTypeFromAPI() && apiType != "" {
+		return apiType == "group"
+	}
+	authProvidersMu.RLock()
+	isGroup, known := principalSchemes[principalScheme(principalID)]
+	authProvidersMu.RUnlock()
+	if known {
+		return isGroup
+	}
+	return apiType == "group"
+}
+
+// principalKeyID returns the identifier used for a principal in annotation keys and
+// GlobalRoleBinding names. IDs that are not valid DNS labels, such as LDAP DNs or SAML
+// group names, are replaced by a stable hash.
+func principalKeyID(principalID string) string {
+	_, id, found := strings.Cut(principalID, "://")
+	if !found {
+		id = principalID
+	}
+	if len(validation.IsDNS1123Label(id)) == 0 {
+		return id
+	}
+	sum := sha256.Sum256([]byte(principalID))
+	return "p" + hex.EncodeToString(sum[:])[:16]
+}
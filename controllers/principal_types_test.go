@@ -0,0 +1,54 @@
+package controllers
+
+import "testing"
+
+func TestIsGroupPrincipal(t *testing.T) {
+	RegisterAuthProvider(AuthProvider{Name: "custom", UserSchemes: []string{"custom_user"}, GroupSchemes: []string{"custom_team"}})
+
+	tests := []struct {
+		name        string
+		principalID string
+		apiType     string
+		fromAPI     bool
+		want        bool
+	}{
+		{name: "github org", principalID: "github_org://42", want: true},
+		{name: "github user", principalID: "github_user://7", want: false},
+		{name: "keycloak group", principalID: "keycloak_group://devs", want: true},
+		{name: "azuread group", principalID: "azuread_group://0c5e", want: true},
+		{name: "openldap group", principalID: "openldap_group://cn=devs,ou=groups,dc=example,dc=org", want: true},
+		{name: "shibboleth group", principalID: "shibboleth_group://devs", want: true},
+		{name: "local user", principalID: "local://u-abc", want: false},
+		{name: "registered provider", principalID: "custom_team://core", want: true},
+		{name: "unknown scheme uses api type", principalID: "unknown://x", apiType: "group", want: true},
+		{name: "unknown scheme without api type", principalID: "unknown://x", want: false},
+		{name: "api type ignored by default", principalID: "keycloak_group://devs", apiType: "user", want: true},
+		{name: "api type trusted", principalID: "keycloak_group://devs", apiType: "user", fromAPI: true, want: false},
+		{name: "api type trusted but missing", principalID: "keycloak_group://devs", fromAPI: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fromAPI {
+				t.Setenv("PRINCIPAL_TYPE_FROM_API", "true")
+			}
+			if got := isGroupPrincipal(tt.principalID, tt.apiType); got != tt.want {
+				t.Fatalf("isGroupPrincipal(%q, %q) = %v, want %v", tt.principalID, tt.apiType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrincipalKeyID(t *testing.T) {
+	if got := principalKeyID("github_org://42"); got != "42" {
+		t.Fatalf("expected plain id, got %q", got)
+	}
+	dn := "openldap_group://cn=devs,ou=groups,dc=example,dc=org"
+	got := principalKeyID(dn)
+	if got == dn || len(got) != 17 || got[0] != 'p' {
+		t.Fatalf("expected hashed id, got %q", got)
+	}
+	if principalKeyID(dn) != got {
+		t.Fatalf("hashed id is not stable")
+	}
+}
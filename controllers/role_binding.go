@@ -1,26 +1,25 @@
 package controllers
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
-	"os"
 	"strings"
 
 	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
 	v3 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/gorizond/fleet-workspace-controller/pkg/rancherapi"
 	"github.com/rancher/lasso/pkg/log"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func createGlobalRoleBinding(mgmt v3.GlobalRoleBindingController, preffix, fleetworkspaceName string, annotationKey string) {
-	parts := strings.SplitN(annotationKey[len(preffix):], ".", 2)
-	userID := parts[0]
-	role := parts[1]
+// createGlobalRoleBinding binds a local user, userPrincipalName is the principal the user was
+// resolved from and may be empty.
+func createGlobalRoleBinding(mgmt v3.GlobalRoleBindingController, preffix, fleetworkspaceName string, annotationKey string, userPrincipalName string) error {
+	userID, role, err := parseMembershipKey(preffix, annotationKey)
+	if err != nil {
+		return err
+	}
 	globalRoleBinding := &managementv3.GlobalRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "gorizond-" + role + "-" + userID + "-" + fleetworkspaceName,
@@ -34,17 +33,18 @@ func createGlobalRoleBinding(mgmt v3.GlobalRoleBindingController, preffix, fleet
 		UserName:       userID,
 		GlobalRoleName: "gorizond-" + role + "-" + fleetworkspaceName,
 	}
-
-	_, err := mgmt.Create(globalRoleBinding)
-	if err != nil && !errors.IsAlreadyExists(err) {
-		log.Infof("Failed to create global role binding: %v", err)
+	if strings.Contains(userPrincipalName, "://") {
+		globalRoleBinding.UserPrincipalName = userPrincipalName
 	}
+
+	return createOrReplaceGlobalRoleBinding(mgmt.Cache(), mgmt, globalRoleBinding)
 }
 
-func createGlobalRoleBindingForGroup(mgmt v3.GlobalRoleBindingController, preffix, fleetworkspaceName string, annotationKey string, groupPrincipalName string) {
-	parts := strings.SplitN(annotationKey[len(preffix):], ".", 2)
-	GroupID := parts[0]
-	role := parts[1]
+func createGlobalRoleBindingForGroup(mgmt v3.GlobalRoleBindingController, preffix, fleetworkspaceName string, annotationKey string, groupPrincipalName string) error {
+	GroupID, role, err := parseMembershipKey(preffix, annotationKey)
+	if err != nil {
+		return err
+	}
 	globalRoleBinding := &managementv3.GlobalRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "gorizond-" + role + "-" + GroupID + "-" + fleetworkspaceName,
@@ -59,246 +59,83 @@ func createGlobalRoleBindingForGroup(mgmt v3.GlobalRoleBindingController, preffi
 		GlobalRoleName: "gorizond-" + role + "-" + fleetworkspaceName,
 	}
 
-	_, err := mgmt.Create(globalRoleBinding)
-	if err != nil && !errors.IsAlreadyExists(err) {
-		log.Infof("Failed to create global role binding: %v", err)
-	}
+	return createOrReplaceGlobalRoleBinding(mgmt.Cache(), mgmt, globalRoleBinding)
 }
 
+// globalRoleBindingWriter is the part of the GlobalRoleBinding controller that writes the
+// bindings of membership annotations.
+type globalRoleBindingWriter interface {
+	Create(*managementv3.GlobalRoleBinding) (*managementv3.GlobalRoleBinding, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+}
 
-func findByPrincipal(users v3.UserController, principal v3.PrincipalController, mgmt v3.GlobalRoleBindingController, fleetworkspace *managementv3.FleetWorkspace, fleetWorkspaces v3.FleetWorkspaceController, annotationKey string, annotationValue string) (*managementv3.FleetWorkspace, error) {
-	parts := strings.SplitN(annotationKey[len("gorizond-principal."):], ".", 2)
-	principalID := annotationValue
-	role := parts[1]
-	// check if group
-	isGroupPrincipal := false
-	if strings.HasPrefix(principalID, "github_org://") {
-		isGroupPrincipal = true
-	}
-	if strings.HasPrefix(principalID, "genericoidc_group://") {
-		isGroupPrincipal = true
+// createOrReplaceGlobalRoleBinding creates the binding. The role and subject of a binding are
+// immutable, so an existing binding of the same name granting something else is deleted and
+// created again once it is gone.
+func createOrReplaceGlobalRoleBinding(cache v3.GlobalRoleBindingCache, client globalRoleBindingWriter, desired *managementv3.GlobalRoleBinding) error {
+	existing, err := cache.Get(desired.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
 	}
-	tmpGlobalRoleBindingName := ""
-	if !isGroupPrincipal {
-		// build tmp GlobalRoleBinding with ttl for rancher create if not exist user/group from principal to rancher user/group
-		globalRoleBindingTMP := &managementv3.GlobalRoleBinding{
-			ObjectMeta: metav1.ObjectMeta{
-				GenerateName: "gorizond-tmp-",
-				Annotations: map[string]string{
-					"type": "user",
-				},
-				Labels: map[string]string{
-					"gorizond-ttl": "30",
-				},
-			},
-			GlobalRoleName: "gorizond-" + role + "-" + fleetworkspace.Name,
-			GroupPrincipalName: principalID,
+	replace := err == nil
+	if replace {
+		if existing.GlobalRoleName == desired.GlobalRoleName &&
+			existing.UserName == desired.UserName &&
+			existing.GroupPrincipalName == desired.GroupPrincipalName {
+			return nil
 		}
-		
-		grbt, err := mgmt.Create(globalRoleBindingTMP)
-		tmpGlobalRoleBindingName = grbt.Name
-		if err != nil && !errors.IsAlreadyExists(err) {
-			log.Infof("Failed to create global role binding: %v", err)
+		if existing.DeletionTimestamp == nil {
+			log.Infof("Replacing global role binding %s with changed subject or role", desired.Name)
+			if err := client.Delete(desired.Name, nil); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
 		}
 	}
-
-	principalObject, err := getLoginName(os.Getenv("RANCHER_URL"), os.Getenv("RANCHER_TOKEN"), principalID)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to get principalID: %v", err)
-	}
-
-	if principalObject.PrincipalType == "group" {
-		groupID := strings.Split(principalID, "://")[1]
-		fleetworkspace.Annotations["gorizond-group." + groupID + "." + role] = annotationValue
-	} else {
-		userlocalID, lenItems, err := findUserByPrincipal(principalObject, principalID, role)
-		if err != nil {
-			return nil, err
-		}
-		if userlocalID == "" {
-			fmt.Errorf("Rancher user for %s not found in %d searched users", principalID, lenItems)
-		} else {
-			fleetworkspace.Annotations["gorizond-user." + userlocalID + "." + role] = annotationValue
+	_, err = client.Create(desired)
+	if errors.IsAlreadyExists(err) {
+		if replace {
+			return fmt.Errorf("waiting for global role binding %s to be removed", desired.Name)
 		}
+		return nil
 	}
-	delete(fleetworkspace.Annotations, annotationKey)
-	// clean tmp grb
-	if tmpGlobalRoleBindingName != "" {
-		log.Infof("tmpGlobalRoleBindingName: " + tmpGlobalRoleBindingName)
-		_ = mgmt.Delete(tmpGlobalRoleBindingName, &metav1.DeleteOptions{})
+	if err != nil {
+		log.Infof("Failed to create global role binding: %v", err)
+		return err
 	}
-	return fleetWorkspaces.Update(fleetworkspace)
+	return nil
 }
 
-func findUserByPrincipal(principalObject Principal, principalID string, role string) (string, int, error) {
-	log.Infof("Try find rancher user for %s", principalObject.LoginName)
-	// find new NOT INIT users
-	searchedUser1, err := findUserByUsername(os.Getenv("RANCHER_URL"), os.Getenv("RANCHER_TOKEN"), "/v3/users?username=")
-	if err != nil {
-		return "", 0, fmt.Errorf("Failed to find /v3/users?username=: %v", err)
-	}
-	log.Infof("Found username=empty %d",len(searchedUser1.Data))
-	// find exist users with username=principal LoginName
-	searchedUser2, err := findUserByUsername(os.Getenv("RANCHER_URL"), os.Getenv("RANCHER_TOKEN"), "/v3/users?username="+ strings.ToLower(principalObject.LoginName))
+
+// RancherAPI is the part of the Rancher REST API the controllers use, implemented by *rancherapi.Client.
+type RancherAPI interface {
+	GetPrincipal(ctx context.Context, id string) (*rancherapi.Principal, error)
+}
+
+// resolvePrincipalAnnotation replaces a gorizond-principal.<id>.<role> annotation on the
+// given workspace copy with the matching gorizond-user. or gorizond-group. annotation.
+// A principal whose user is still being provisioned keeps its annotation, the time its user
+// was first requested is read from the provisioning annotation.
+func resolvePrincipalAnnotation(ctx context.Context, users *userProvisioner, fleetworkspace *managementv3.FleetWorkspace, annotationKey string, annotationValue string) error {
+	_, role, err := parseMembershipKey("gorizond-principal.", annotationKey)
 	if err != nil {
-		return "", 0, fmt.Errorf("Failed to find /v3/users?username=principalObject.LoginName: %v", err)
+		return err
 	}
-	log.Infof("Found username=%s %d",strings.ToLower(principalObject.LoginName), len(searchedUser2.Data))
-	// find exist users with name=principal LoginName
-	searchedUser3, err := findUserByUsername(os.Getenv("RANCHER_URL"), os.Getenv("RANCHER_TOKEN"), "/v3/users?name=" + strings.ToLower(principalObject.LoginName))
+	principalID := annotationValue
+
+	userlocalID, isGroup, err := users.resolve(ctx, principalID, provisioningRequested(fleetworkspace.Annotations, principalID))
 	if err != nil {
-		return "", 0, fmt.Errorf("Failed to find /v3/users?name=principalObject.LoginName: %v", err)
+		return err
 	}
-	log.Infof("Found name=%s %d",strings.ToLower(principalObject.LoginName), len(searchedUser3.Data))
-	items := append(searchedUser3.Data, searchedUser2.Data...)
-	// if by name not found may by its 'admin'?
-	if len(items) == 0 {
-		// try get admin
-		log.Infof("try get admin")
-		admin, err := findUserByUsername(os.Getenv("RANCHER_URL"), os.Getenv("RANCHER_TOKEN"), "/v3/users?username=admin")
-		if err != nil {
-			return "", 0, fmt.Errorf("Failed to find /v3/users?username=admin: %v", err)
-		}
-		items = append(items, admin.Data...)
+	resolvedKey := "gorizond-user." + userlocalID + "." + role
+	if isGroup {
+		resolvedKey = "gorizond-group." + principalKeyID(principalID) + "." + role
 	}
-	items = append(items, searchedUser1.Data...)
-	userFind := false
-	userlocalID := ""
-	for _, user := range items {
-		// iter all PrincipalIDs
-		if user.PrincipalIDs != nil {
-			for _, iterPrincipal := range user.PrincipalIDs {
-				if iterPrincipal == principalID {
-					userFind = true
-				}
-				if userFind {
-					for _, iter := range user.PrincipalIDs {
-						if userlocalID == "" && strings.HasPrefix(iter, "local://") {
-							userlocalID = strings.Split(iter, "://")[1]
-							break
-						}
-					}
-				}
-			}
-		}
-		if userFind && userlocalID != "" {
-			break
-		}
+	fleetworkspace.Annotations[resolvedKey] = annotationValue
+	delete(fleetworkspace.Annotations, annotationKey)
+	// an expiry follows the membership it was set on
+	if expiry, ok := fleetworkspace.Annotations[membershipExpiryPrefix+annotationKey]; ok {
+		fleetworkspace.Annotations[membershipExpiryPrefix+resolvedKey] = expiry
+		delete(fleetworkspace.Annotations, membershipExpiryPrefix+annotationKey)
 	}
-	return userlocalID, len(items), nil
-}
-
-type Principal struct {
-    LoginName string `json:"loginName"`
-    PrincipalType string `json:"principalType"`
-}
-type SearchedUser struct {
-    LoginName string `json:"loginName"`
-    PrincipalType string `json:"principalType"`
-}
-
-func getLoginName(rancherURL, token, principalID string) (Principal, error) {
-    // Escape the principal identifier for use in the URL
-    encodedID := url.PathEscape(principalID)
-    endpoint := fmt.Sprintf("%s/v3/principals/%s", rancherURL, encodedID)
-
-    // Create an HTTP client with certificate verification disabled (for example purposes)
-    client := &http.Client{
-        Transport: &http.Transport{
-            TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-        },
-    }
-
-    var principal Principal
-    // Formulate the HTTP request
-    req, err := http.NewRequest("GET", endpoint, nil)
-    if err != nil {
-        return principal, fmt.Errorf("error creating request: %v", err)
-    }
-
-    // Add the authorization header
-    req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-
-    // Execute the request
-    resp, err := client.Do(req)
-    if err != nil {
-        return principal, fmt.Errorf("error executing request: %v", err)
-    }
-    defer resp.Body.Close()
-
-    // Check the response status code
-    if resp.StatusCode != http.StatusOK {
-        return principal, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-    }
-
-    // Read the response body
-    body, err := ioutil.ReadAll(resp.Body)
-    if err != nil {
-        return principal, fmt.Errorf("error reading response body: %v", err)
-    }
-
-    // Parse the JSON response
-    if err := json.Unmarshal(body, &principal); err != nil {
-        return principal, fmt.Errorf("error parsing JSON: %v", err)
-    }
-
-    return principal, nil
-}
-
-type User struct {
-    ID         string `json:"id"`
-    Username   string `json:"username"`
-    Name       string `json:"name"`
-    PrincipalIDs []string `json:"principalIds"`
-}
-
-type UserCollection struct {
-    Data []User `json:"data"`
-}
-
-func findUserByUsername(rancherURL, token, query string) (*UserCollection, error) {
-    // Escape the username for use in the URL
-    endpoint := rancherURL + query
-
-    // Create an HTTP client with certificate verification disabled (for example purposes)
-    client := &http.Client{
-        Transport: &http.Transport{
-            TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-        },
-    }
-
-    // Formulate the HTTP request
-    req, err := http.NewRequest("GET", endpoint, nil)
-    if err != nil {
-        return nil, fmt.Errorf("error creating request: %v", err)
-    }
-
-    // Add the authorization header
-    req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-
-    // Execute the request
-    resp, err := client.Do(req)
-    if err != nil {
-        return nil, fmt.Errorf("error executing request: %v", err)
-    }
-    defer resp.Body.Close()
-
-    // Check the response status code
-    if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-    }
-
-    // Read the response body
-    body, err := ioutil.ReadAll(resp.Body)
-    if err != nil {
-        return nil, fmt.Errorf("error reading response body: %v", err)
-    }
-
-    // Parse the JSON response
-    var users UserCollection
-    if err := json.Unmarshal(body, &users); err != nil {
-        return nil, fmt.Errorf("error parsing JSON: %v", err)
-    }
-
-    return &users, nil
+	return nil
 }
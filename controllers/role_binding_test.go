@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"testing"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"github.com/gorizond/fleet-workspace-controller/pkg/rancherapi"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeRancher serves principals by ID.
+type fakeRancher struct {
+	principals map[string]rancherapi.Principal
+}
+
+func (f *fakeRancher) GetPrincipal(_ context.Context, id string) (*rancherapi.Principal, error) {
+	principal, ok := f.principals[id]
+	if !ok {
+		return nil, &rancherapi.StatusError{StatusCode: 404}
+	}
+	return &principal, nil
+}
+
+// fakeUserCreator records created users without making them visible in the cache.
+type fakeUserCreator struct {
+	created []*managementv3.User
+}
+
+func (f *fakeUserCreator) Create(user *managementv3.User) (*managementv3.User, error) {
+	for _, u := range f.created {
+		if u.Name == user.Name {
+			return nil, errors.NewAlreadyExists(schema.GroupResource{}, user.Name)
+		}
+	}
+	f.created = append(f.created, user)
+	return user, nil
+}
+
+func TestUserProvisionerResolve(t *testing.T) {
+	rancher := &fakeRancher{principals: map[string]rancherapi.Principal{
+		"github_user://7":      {LoginName: "octocat", PrincipalType: "user"},
+		"github_user://9":      {Name: "New Person", LoginName: "newbie", PrincipalType: "user"},
+		"github_user://12":     {LoginName: "taken", PrincipalType: "user"},
+		"github_org://42":      {LoginName: "gorizond", PrincipalType: "group"},
+		"keycloak_group://ops": {LoginName: "ops", PrincipalType: "group"},
+	}}
+	users := newFakeCache(
+		&managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-abc"}, PrincipalIDs: []string{"github_user://7", "local://u-abc"}},
+		&managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-dup1"}, PrincipalIDs: []string{"github_user://11"}},
+		&managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-dup2"}, PrincipalIDs: []string{"github_user://11"}},
+		&managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: provisionedUserName("github_user://12")}, PrincipalIDs: []string{"github_user://99"}},
+	)
+	users.AddIndexer(userByPrincipalIndex, userPrincipalIndexer)
+	creator := &fakeUserCreator{}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := &userProvisioner{
+		rancher: rancher,
+		users:   users,
+		creator: creator,
+		now:     func() time.Time { return now },
+	}
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		principalID string
+		wantUser    string
+		wantGroup   bool
+	}{
+		{principalID: "github_user://7", wantUser: "u-abc"},
+		{principalID: "local://u-abc", wantUser: "u-abc"},
+		{principalID: "github_org://42", wantGroup: true},
+		{principalID: "keycloak_group://ops", wantGroup: true},
+	} {
+		user, isGroup, err := p.resolve(ctx, tt.principalID, time.Time{})
+		if err != nil || user != tt.wantUser || isGroup != tt.wantGroup {
+			t.Fatalf("%s: got user=%q group=%v err=%v", tt.principalID, user, isGroup, err)
+		}
+	}
+	for _, principalID := range []string{"github_user://11", "github_user://12", "github_user://8"} {
+		if _, _, err := p.resolve(ctx, principalID, time.Time{}); err == nil {
+			t.Fatalf("%s: expected an error", principalID)
+		}
+	}
+	if len(creator.created) != 0 {
+		t.Fatalf("no user must be created for resolved, group or invalid principals, got %d", len(creator.created))
+	}
+
+	// an unknown user principal is requested once and stays pending until the cache has it,
+	// the first request time is handed back for the caller to persist
+	var requested time.Time
+	first := now
+	var pendingErr *userPendingError
+	for i := 0; i < 2; i++ {
+		if _, _, err := p.resolve(ctx, "github_user://9", requested); !goerrors.As(err, &pendingErr) {
+			t.Fatalf("expected a pending error, got %v", err)
+		}
+		if !pendingErr.requested.Equal(first) {
+			t.Fatalf("expected the request time %s, got %s", first, pendingErr.requested)
+		}
+		requested = pendingErr.requested
+		now = now.Add(userProvisioningRetry)
+	}
+	if len(creator.created) != 1 {
+		t.Fatalf("expected exactly one user request, got %d", len(creator.created))
+	}
+	created := creator.created[0]
+	if created.Name != provisionedUserName("github_user://9") || created.DisplayName != "New Person" ||
+		len(created.PrincipalIDs) != 2 || created.PrincipalIDs[1] != "local://"+created.Name {
+		t.Fatalf("unexpected user request %+v", created)
+	}
+
+	// the timeout counts from the persisted first request and stays reported
+	now = requested.Add(userProvisioningTimeout + time.Second)
+	var timeoutErr *userProvisioningTimeoutError
+	for i := 0; i < 2; i++ {
+		if _, _, err := p.resolve(ctx, "github_user://9", requested); !goerrors.As(err, &timeoutErr) {
+			t.Fatalf("expected a timeout error, got %v", err)
+		}
+	}
+	delete(rancher.principals, "github_user://9")
+	if _, _, err := p.resolve(ctx, "github_user://9", requested); !goerrors.As(err, &timeoutErr) {
+		t.Fatalf("a timed out principal must not be looked up again, got %v", err)
+	}
+
+	users.objs[created.Name] = created
+	if user, _, err := p.resolve(ctx, "github_user://9", requested); err != nil || user != created.Name {
+		t.Fatalf("expected provisioned user %s, got %q %v", created.Name, user, err)
+	}
+}
+
+// fakeGlobalRoleBindingWriter records writes and fails creates of names that still exist.
+type fakeGlobalRoleBindingWriter struct {
+	existing map[string]bool
+	created  []*managementv3.GlobalRoleBinding
+	deleted  []string
+}
+
+func (f *fakeGlobalRoleBindingWriter) Create(obj *managementv3.GlobalRoleBinding) (*managementv3.GlobalRoleBinding, error) {
+	if f.existing[obj.Name] {
+		return nil, errors.NewAlreadyExists(schema.GroupResource{}, obj.Name)
+	}
+	f.created = append(f.created, obj)
+	return obj, nil
+}
+
+func (f *fakeGlobalRoleBindingWriter) Delete(name string, _ *metav1.DeleteOptions) error {
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+func TestCreateOrReplaceGlobalRoleBinding(t *testing.T) {
+	binding := func(role, user, group string) *managementv3.GlobalRoleBinding {
+		return &managementv3.GlobalRoleBinding{
+			ObjectMeta:         metav1.ObjectMeta{Name: "gorizond-admin-u-abc-workspace-demo"},
+			GlobalRoleName:     role,
+			UserName:           user,
+			GroupPrincipalName: group,
+		}
+	}
+	desired := binding("gorizond-admin-workspace-demo", "u-abc", "")
+	deleting := binding("gorizond-view-workspace-demo", "u-abc", "")
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	tests := []struct {
+		name        string
+		existing    *managementv3.GlobalRoleBinding
+		stale       bool
+		wantErr     bool
+		wantDeleted bool
+		wantCreated bool
+	}{
+		{name: "missing", wantCreated: true},
+		{name: "missing from a stale cache", stale: true},
+		{name: "up to date", existing: binding("gorizond-admin-workspace-demo", "u-abc", "")},
+		{name: "other role", existing: binding("gorizond-view-workspace-demo", "u-abc", ""), stale: true, wantErr: true, wantDeleted: true},
+		{name: "other user", existing: binding("gorizond-admin-workspace-demo", "u-other", ""), wantDeleted: true, wantCreated: true},
+		{name: "group subject", existing: binding("gorizond-admin-workspace-demo", "", "github_org://42"), wantDeleted: true, wantCreated: true},
+		{name: "being deleted", existing: deleting, stale: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newFakeCache[*managementv3.GlobalRoleBinding]()
+			if tt.existing != nil {
+				cache = newFakeCache(tt.existing)
+			}
+			writer := &fakeGlobalRoleBindingWriter{existing: map[string]bool{desired.Name: tt.stale}}
+			err := createOrReplaceGlobalRoleBinding(cache, writer, desired)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if (len(writer.deleted) > 0) != tt.wantDeleted {
+				t.Fatalf("expected deleted=%v, got %v", tt.wantDeleted, writer.deleted)
+			}
+			if (len(writer.created) > 0) != tt.wantCreated {
+				t.Fatalf("expected created=%v, got %d creates", tt.wantCreated, len(writer.created))
+			}
+		})
+	}
+}
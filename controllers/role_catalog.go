@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"text/template"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
+	"github.com/rancher/lasso/pkg/log"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultConfigNamespace     = "cattle-fleet-system"
+	defaultRoleCatalogName     = "fleet-workspace-controller-roles"
+	roleCatalogKey             = "roles.yaml"
+	adminRole                  = "admin"
+	defaultRoleDisplayTemplate = "GitOps for {{ .Role }} {{ .Workspace }}"
+)
+
+// RoleCatalog describes the GlobalRoles created for every FleetWorkspace.
+type RoleCatalog struct {
+	Roles []RoleTemplate `json:"roles"`
+}
+
+// RoleTemplate is a single `gorizond-<role>-<workspace>` GlobalRole.
+// DisplayName and ResourceNames are rendered as Go templates with
+// `.Role` and `.Workspace`; NamespacedRules are scoped to the workspace namespace.
+type RoleTemplate struct {
+	Name            string              `json:"name"`
+	DisplayName     string              `json:"displayName,omitempty"`
+	Rules           []rbacv1.PolicyRule `json:"rules,omitempty"`
+	NamespacedRules []rbacv1.PolicyRule `json:"namespacedRules,omitempty"`
+}
+
+type roleTemplateData struct {
+	Role      string
+	Workspace string
+}
+
+// roleCatalog is the active catalog. Handlers read it from the ConfigMap cache, so roles
+// missing from the built-in catalog are never deleted before the configured one was loaded.
+var roleCatalog = newConfigMapSetting(getRoleCatalogName, roleCatalogKey, parseRoleCatalog, defaultRoleCatalog)
+
+func defaultRoleCatalog() *RoleCatalog {
+	workspaceRules := func(verbs, billingVerbs []string) []rbacv1.PolicyRule {
+		return []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{"management.cattle.io"},
+				ResourceNames: []string{"{{ .Workspace }}"},
+				Resources:     []string{"fleetworkspaces"},
+				Verbs:         verbs,
+			},
+			{
+				APIGroups:     []string{"provisioning.gorizond.io"},
+				ResourceNames: []string{"{{ .Workspace }}"},
+				Resources:     []string{"clusters"},
+				Verbs:         verbs,
+			},
+			{
+				APIGroups:     []string{"provisioning.gorizond.io"},
+				ResourceNames: []string{"{{ .Workspace }}"},
+				Resources:     []string{"billings", "billingevents"},
+				Verbs:         billingVerbs,
+			},
+		}
+	}
+	namespacedRules := func(verbs, billingVerbs []string) []rbacv1.PolicyRule {
+		return []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{"fleet.cattle.io"},
+				Resources: []string{"gitrepos", "bundles", "clusterregistrationtokens", "gitreporestrictions", "clusters", "clustergroups"},
+				Verbs:     verbs,
+			},
+			{
+				APIGroups: []string{"provisioning.gorizond.io"},
+				Resources: []string{"clusters"},
+				Verbs:     verbs,
+			},
+			{
+				APIGroups: []string{"provisioning.gorizond.io"},
+				Resources: []string{"billings", "billingevents"},
+				Verbs:     billingVerbs,
+			},
+		}
+	}
+
+	readVerbs := []string{"get", "list", "watch"}
+	roles := []struct {
+		name         string
+		verbs        []string
+		billingVerbs []string
+	}{
+		{name: adminRole, verbs: []string{"*"}, billingVerbs: []string{"create", "delete", "get", "list", "watch"}},
+		{name: "editor", verbs: []string{"get", "list", "watch", "update", "patch"}, billingVerbs: readVerbs},
+		{name: "view", verbs: readVerbs, billingVerbs: readVerbs},
+	}
+
+	catalog := &RoleCatalog{}
+	for _, r := range roles {
+		catalog.Roles = append(catalog.Roles, RoleTemplate{
+			Name:            r.name,
+			DisplayName:     defaultRoleDisplayTemplate,
+			Rules:           workspaceRules(r.verbs, r.billingVerbs),
+			NamespacedRules: namespacedRules(r.verbs, r.billingVerbs),
+		})
+	}
+	return catalog
+}
+
+func parseRoleCatalog(data []byte) (*RoleCatalog, error) {
+	catalog := &RoleCatalog{}
+	if err := yaml.UnmarshalStrict(data, catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse role catalog: %w", err)
+	}
+	if err := catalog.validate(); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+func (c *RoleCatalog) validate() error {
+	seen := map[string]bool{}
+	for _, role := range c.Roles {
+		if errs := validation.IsDNS1123Label(role.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid role name %q: %v", role.Name, errs)
+		}
+		if seen[role.Name] {
+			return fmt.Errorf("duplicate role %q", role.Name)
+		}
+		seen[role.Name] = true
+		if _, err := role.render(&managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "validate"}}); err != nil {
+			return err
+		}
+	}
+	if !seen[adminRole] {
+		return fmt.Errorf("role catalog must define the %q role", adminRole)
+	}
+	return nil
+}
+
+// Role returns the catalog entry for name.
+func (c *RoleCatalog) Role(name string) (RoleTemplate, bool) {
+	for _, role := range c.Roles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+	return RoleTemplate{}, false
+}
+
+// globalRoleName is the name of the GlobalRole granting role in a workspace.
+func globalRoleName(role, workspace string) string {
+	return "gorizond-" + role + "-" + workspace
+}
+
+// render builds the GlobalRole for this template in the given workspace.
+func (r RoleTemplate) render(fleetworkspace *managementv3.FleetWorkspace) (*managementv3.GlobalRole, error) {
+	data := roleTemplateData{Role: r.Name, Workspace: fleetworkspace.Name}
+
+	displayTemplate := r.DisplayName
+	if displayTemplate == "" {
+		displayTemplate = defaultRoleDisplayTemplate
+	}
+	displayName, err := renderTemplate(displayTemplate, data)
+	if err != nil {
+		return nil, fmt.Errorf("role %q displayName: %w", r.Name, err)
+	}
+	rules, err := renderRules(r.Rules, data)
+	if err != nil {
+		return nil, fmt.Errorf("role %q rules: %w", r.Name, err)
+	}
+	namespacedRules, err := renderRules(r.NamespacedRules, data)
+	if err != nil {
+		return nil, fmt.Errorf("role %q namespacedRules: %w", r.Name, err)
+	}
+
+	globalRole := &managementv3.GlobalRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: globalRoleName(r.Name, fleetworkspace.Name),
+			Labels: map[string]string{
+				"role":  r.Name,
+				"fleet": fleetworkspace.Name,
+			},
+		},
+		DisplayName: displayName,
+		Rules:       rules,
+	}
+	if len(namespacedRules) > 0 {
+		globalRole.NamespacedRules = map[string][]rbacv1.PolicyRule{
+			fleetworkspace.Name: namespacedRules,
+		}
+	}
+	return globalRole, nil
+}
+
+func renderRules(rules []rbacv1.PolicyRule, data roleTemplateData) ([]rbacv1.PolicyRule, error) {
+	var result []rbacv1.PolicyRule
+	for _, rule := range rules {
+		rule = *rule.DeepCopy()
+		for i, name := range rule.ResourceNames {
+			rendered, err := renderTemplate(name, data)
+			if err != nil {
+				return nil, err
+			}
+			rule.ResourceNames[i] = rendered
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+func renderTemplate(text string, data roleTemplateData) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ConfigNamespace is the namespace holding the controller ConfigMaps.
+func ConfigNamespace() string {
+	if env := os.Getenv("CONFIG_NAMESPACE"); env != "" {
+		return env
+	}
+	return defaultConfigNamespace
+}
+
+func getRoleCatalogName() string {
+	if env := os.Getenv("ROLE_CATALOG_CONFIGMAP"); env != "" {
+		return env
+	}
+	return defaultRoleCatalogName
+}
+
+// InitRoleCatalogController loads the role catalog ConfigMap and re-enqueues
+// every FleetWorkspace whenever the effective catalog changes.
+func InitRoleCatalogController(ctx context.Context, mgmt *management.Factory, configMaps corecontrollers.ConfigMapController) {
+	fleetWorkspaces := mgmt.Management().V3().FleetWorkspace()
+	namespace := ConfigNamespace()
+	name := getRoleCatalogName()
+
+	configMaps.OnChange(ctx, "gorizond-role-catalog-controller", metrics.Instrument("gorizond-role-catalog-controller", func(key string, obj *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+		if key != namespace+"/"+name {
+			return obj, nil
+		}
+
+		changed, err := roleCatalog.sync(obj)
+		if err != nil {
+			// keep the previous catalog instead of revoking access on a typo
+			log.Errorf("Ignoring invalid role catalog %s: %v", key, err)
+			return obj, nil
+		}
+		if !changed {
+			return obj, nil
+		}
+
+		log.Infof("Role catalog %s changed, reconciling all fleet workspaces", key)
+		workspaces, err := fleetWorkspaces.Cache().List(labels.Everything())
+		if err != nil {
+			return obj, err
+		}
+		for _, ws := range workspaces {
+			fleetWorkspaces.Enqueue(ws.Name)
+		}
+		return obj, nil
+	}))
+}
+
+type configMapGetter interface {
+	Get(namespace, name string, opts metav1.GetOptions) (*corev1.ConfigMap, error)
+}
+
+// loadRoleCatalog reads the role catalog ConfigMap from the API, for replicas that do not run
+// the catalog controller and so have no catalog of their own to fall back to on a typo.
+func loadRoleCatalog(configMaps configMapGetter) (*RoleCatalog, error) {
+	obj, err := configMaps.Get(ConfigNamespace(), getRoleCatalogName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return defaultRoleCatalog(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := obj.Data[roleCatalogKey]
+	if !ok {
+		return defaultRoleCatalog(), nil
+	}
+	catalog, err := parseRoleCatalog([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid role catalog %s/%s: %w", ConfigNamespace(), getRoleCatalogName(), err)
+	}
+	return catalog, nil
+}
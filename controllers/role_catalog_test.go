@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDefaultRoleCatalogRender(t *testing.T) {
+	ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-demo"}}
+	catalog := defaultRoleCatalog()
+	if err := catalog.validate(); err != nil {
+		t.Fatalf("default catalog invalid: %v", err)
+	}
+
+	admin, ok := catalog.Role("admin")
+	if !ok {
+		t.Fatalf("expected admin role in default catalog")
+	}
+	globalRole, err := admin.render(ws)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if globalRole.Name != "gorizond-admin-workspace-demo" {
+		t.Fatalf("unexpected name %q", globalRole.Name)
+	}
+	if globalRole.DisplayName != "GitOps for admin workspace-demo" {
+		t.Fatalf("unexpected display name %q", globalRole.DisplayName)
+	}
+	if globalRole.Labels["fleet"] != "workspace-demo" || globalRole.Labels["role"] != "admin" {
+		t.Fatalf("unexpected labels %v", globalRole.Labels)
+	}
+	if got := globalRole.Rules[0].ResourceNames; !reflect.DeepEqual(got, []string{"workspace-demo"}) {
+		t.Fatalf("expected resource names rendered, got %v", got)
+	}
+	if _, ok := globalRole.NamespacedRules["workspace-demo"]; !ok {
+		t.Fatalf("expected namespaced rules for workspace namespace, got %v", globalRole.NamespacedRules)
+	}
+	// the template must not leak into the catalog
+	if admin.Rules[0].ResourceNames[0] != "{{ .Workspace }}" {
+		t.Fatalf("catalog mutated by render: %v", admin.Rules[0].ResourceNames)
+	}
+}
+
+func TestParseRoleCatalog(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+		roles   int
+	}{
+		{
+			name: "custom roles",
+			data: `
+roles:
+- name: admin
+  rules:
+  - apiGroups: ["management.cattle.io"]
+    resources: ["fleetworkspaces"]
+    resourceNames: ["{{ .Workspace }}"]
+    verbs: ["*"]
+- name: deployer
+  displayName: "Deployer {{ .Workspace }}"
+  namespacedRules:
+  - apiGroups: ["fleet.cattle.io"]
+    resources: ["gitrepos"]
+    verbs: ["*"]
+`,
+			roles: 2,
+		},
+		{
+			name:    "admin role is required",
+			data:    "roles:\n- name: deployer\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate roles",
+			data:    "roles:\n- name: admin\n- name: admin\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid role name",
+			data:    "roles:\n- name: admin\n- name: Bad.Name\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown template field",
+			data:    "roles:\n- name: admin\n  displayName: \"{{ .Owner }}\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown catalog field",
+			data:    "roles:\n- name: admin\n  verbs: [\"*\"]\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog, err := parseRoleCatalog([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if err == nil && len(catalog.Roles) != tt.roles {
+				t.Fatalf("expected %d roles, got %d", tt.roles, len(catalog.Roles))
+			}
+		})
+	}
+}
+
+// apiConfigMaps serves fakeConfigMaps like the ConfigMap client the webhook reads from.
+type apiConfigMaps struct{ fakeConfigMaps }
+
+func (f apiConfigMaps) Get(namespace, name string, _ metav1.GetOptions) (*corev1.ConfigMap, error) {
+	return f.fakeConfigMaps.Get(namespace, name)
+}
+
+func TestLoadRoleCatalog(t *testing.T) {
+	if catalog, err := loadRoleCatalog(apiConfigMaps{fakeConfigMaps{}}); err != nil || len(catalog.Roles) != 3 {
+		t.Fatalf("loadRoleCatalog() = %v, %v, want the built-in catalog", catalog, err)
+	}
+	custom := configMapWith(getRoleCatalogName(), roleCatalogKey, "roles:\n- name: admin\n- name: deployer\n")
+	if catalog, err := loadRoleCatalog(apiConfigMaps{custom}); err != nil || len(catalog.Roles) != 2 {
+		t.Fatalf("loadRoleCatalog() = %v, %v, want the custom catalog", catalog, err)
+	}
+	// a replica without a catalog of its own must not fall back to the built-in one
+	invalid := configMapWith(getRoleCatalogName(), roleCatalogKey, "roles: [")
+	if _, err := loadRoleCatalog(apiConfigMaps{invalid}); err == nil {
+		t.Fatal("expected an invalid catalog to fail")
+	}
+}
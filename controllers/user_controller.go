@@ -4,17 +4,59 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
 	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	v3 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
 	"github.com/rancher/lasso/pkg/log"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
+// userByPrincipalIndex indexes Users by every entry of PrincipalIDs, local:// included.
+const userByPrincipalIndex = "gorizond-user-by-principal"
+
+var userIndexerOnce sync.Once
+
+// addUserIndexers registers the User cache indexes once, whichever controller asks first.
+func addUserIndexers(users v3.UserCache) {
+	userIndexerOnce.Do(func() {
+		users.AddIndexer(userByPrincipalIndex, userPrincipalIndexer)
+	})
+}
+
+func userPrincipalIndexer(obj *managementv3.User) ([]string, error) {
+	return obj.PrincipalIDs, nil
+}
+
+// userForPrincipal returns the local user that owns principalID, empty when Rancher
+// has not created one yet.
+func userForPrincipal(users v3.UserCache, principalID string) (string, error) {
+	matches, err := users.GetByIndex(userByPrincipalIndex, principalID)
+	if err != nil {
+		return "", err
+	}
+	switch len(matches) {
+	case 0:
+		return "", nil
+	case 1:
+		return matches[0].Name, nil
+	}
+	names := make([]string, 0, len(matches))
+	for _, user := range matches {
+		names = append(names, user.Name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("principal %s belongs to several users: %s", principalID, strings.Join(names, ", "))
+}
+
 type userPatcher interface {
 	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*managementv3.User, error)
 }
@@ -62,99 +104,182 @@ func findActiveWorkspaceForUser(fleetWorkspaces interface {
 	return bestName, nil
 }
 
-func InitUserController(ctx context.Context, mgmt *management.Factory) {
-	users := mgmt.Management().V3().User()
-	fleetWorkspaces := mgmt.Management().V3().FleetWorkspace()
+// fleetWorkspaceClient is the part of the FleetWorkspace client the user controller needs.
+type fleetWorkspaceClient interface {
+	Get(name string, opts metav1.GetOptions) (*managementv3.FleetWorkspace, error)
+	Create(*managementv3.FleetWorkspace) (*managementv3.FleetWorkspace, error)
+	List(opts metav1.ListOptions) (*managementv3.FleetWorkspaceList, error)
+}
 
-	users.OnChange(ctx, "gorizond-user-controller", func(key string, obj *managementv3.User) (*managementv3.User, error) {
-		if obj == nil {
-			return nil, nil
-		}
+type userHandler struct {
+	users           userPatcher
+	fleetWorkspaces fleetWorkspaceClient
+	userAttributes  userAttributeGetter
+	configMaps      configMapReader
+	invitations     *invitationMatcher
+}
 
-		// check non system users
-		if obj.Status.Conditions == nil {
-			return nil, nil
-		}
+type userAttributeGetter interface {
+	Get(name string) (*managementv3.UserAttribute, error)
+}
+
+// selfWorkspaceName is the deterministic name of a user's personal workspace, so a handler
+// re-running before the cache sees the workspace converges on the same object.
+func selfWorkspaceName(userID string) string {
+	return workspacePrefix + userID
+}
+
+// InitUserController provisions personal workspaces and hands users to the invitations
+// naming them, invitations may be nil.
+func InitUserController(ctx context.Context, mgmt *management.Factory, configMaps corecontrollers.ConfigMapCache, invitations *invitationMatcher) {
+	h := &userHandler{
+		users:           mgmt.Management().V3().User(),
+		fleetWorkspaces: mgmt.Management().V3().FleetWorkspace(),
+		userAttributes:  mgmt.Management().V3().UserAttribute().Cache(),
+		configMaps:      configMaps,
+		invitations:     invitations,
+	}
+	mgmt.Management().V3().User().OnChange(ctx, "gorizond-user-controller", metrics.Instrument("gorizond-user-controller", h.onChange))
+}
 
-		// ignore system users
-		for _, id := range obj.PrincipalIDs {
-			if strings.HasPrefix(id, "system://") {
-				if obj.Annotations == nil || obj.Annotations[selfWorkspaceInitAnnotation] != "true" {
-					if err := patchUserAnnotations(users, obj.Name, map[string]interface{}{
-						selfWorkspaceInitAnnotation: "true",
-					}); err != nil {
-						return obj, err
-					}
+func (h *userHandler) onChange(key string, obj *managementv3.User) (*managementv3.User, error) {
+	if obj == nil {
+		return nil, nil
+	}
+
+	// check non system users
+	if obj.Status.Conditions == nil {
+		return nil, nil
+	}
+
+	// ignore system users
+	for _, id := range obj.PrincipalIDs {
+		if strings.HasPrefix(id, "system://") {
+			if obj.Annotations == nil || obj.Annotations[selfWorkspaceInitAnnotation] != "true" {
+				if err := patchUserAnnotations(h.users, obj.Name, map[string]interface{}{
+					selfWorkspaceInitAnnotation: "true",
+				}); err != nil {
+					return obj, err
 				}
-				return obj, nil
 			}
+			return obj, nil
 		}
+	}
 
-		selfFleet := ""
-		selfInit := false
-		if obj.Annotations != nil {
-			selfFleet = obj.Annotations[userSelfFleetAnnotation]
-			selfInit = obj.Annotations[selfWorkspaceInitAnnotation] == "true"
+	if h.invitations != nil {
+		if err := h.invitations.enqueueFor(obj); err != nil {
+			return obj, err
 		}
+	}
 
-		// If the user doesn't have a default workspace set, try to adopt an existing one.
-		if selfFleet == "" {
-			existing, err := findActiveWorkspaceForUser(fleetWorkspaces, obj.Name)
-			if err != nil {
+	selfFleet := ""
+	selfInit := false
+	if obj.Annotations != nil {
+		selfFleet = obj.Annotations[userSelfFleetAnnotation]
+		selfInit = obj.Annotations[selfWorkspaceInitAnnotation] == "true"
+	}
+
+	// If the user doesn't have a default workspace set, try to adopt an existing one.
+	if selfFleet == "" {
+		existing, err := findActiveWorkspaceForUser(h.fleetWorkspaces, obj.Name)
+		if err != nil {
+			return obj, err
+		}
+		if existing != "" {
+			if err := patchUserAnnotations(h.users, obj.Name, map[string]interface{}{
+				selfWorkspaceInitAnnotation: "true",
+				userSelfFleetAnnotation:     existing,
+			}); err != nil {
 				return obj, err
 			}
-			if existing != "" {
-				if err := patchUserAnnotations(users, obj.Name, map[string]interface{}{
+			return obj, nil
+		}
+	}
+
+	// If the user has a default workspace, ensure it still exists.
+	if selfFleet != "" {
+		ws, err := h.fleetWorkspaces.Get(selfFleet, metav1.GetOptions{})
+		if err == nil && ws != nil && ws.DeletionTimestamp == nil {
+			if !selfInit {
+				if err := patchUserAnnotations(h.users, obj.Name, map[string]interface{}{
 					selfWorkspaceInitAnnotation: "true",
-					userSelfFleetAnnotation:     existing,
 				}); err != nil {
 					return obj, err
 				}
-				return obj, nil
 			}
+			return obj, nil
 		}
+		if err != nil && !errors.IsNotFound(err) {
+			return obj, err
+		}
+	}
 
-		// If the user has a default workspace, ensure it still exists.
-		if selfFleet != "" {
-			ws, err := fleetWorkspaces.Get(selfFleet, metav1.GetOptions{})
-			if err == nil && ws != nil && ws.DeletionTimestamp == nil {
-				if !selfInit {
-					if err := patchUserAnnotations(users, obj.Name, map[string]interface{}{
-						selfWorkspaceInitAnnotation: "true",
-					}); err != nil {
-						return obj, err
-					}
-				}
-				return obj, nil
-			}
-			if err != nil && !errors.IsNotFound(err) {
+	// Users the policy opts out get no workspace, only a note telling why.
+	policy, err := workspacePolicy.read(h.configMaps)
+	if err != nil {
+		return obj, err
+	}
+	provision, reason := policy.decide(obj, userGroups(h.userAttributes, obj.Name))
+	if !provision {
+		if obj.Annotations[selfWorkspaceSkippedAnnotation] != reason {
+			if err := patchUserAnnotations(h.users, obj.Name, map[string]interface{}{
+				selfWorkspaceSkippedAnnotation: reason,
+			}); err != nil {
 				return obj, err
 			}
 		}
+		return obj, nil
+	}
 
-		// Create a new workspace and mark it as user's default.
-		fwName := fmt.Sprintf("%s%s-%d", workspacePrefix, obj.Name, time.Now().UnixNano())
-		fleetworkspace := &managementv3.FleetWorkspace{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: fwName,
-				Annotations: map[string]string{
-					"field.cattle.io/creatorId": obj.Name,
-				},
+	// Create the personal workspace and mark it as user's default. An existing workspace
+	// of that name is adopted when it belongs to the user.
+	fwName := selfWorkspaceName(obj.Name)
+	fleetworkspace := &managementv3.FleetWorkspace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fwName,
+			Annotations: map[string]string{
+				"field.cattle.io/creatorId": obj.Name,
 			},
-		}
+		},
+	}
 
-		if _, err := fleetWorkspaces.Create(fleetworkspace); err != nil && !errors.IsAlreadyExists(err) {
-			log.Infof("Failed to create fleetworkspace %s: %v", obj.Username, err)
+	if _, err := h.fleetWorkspaces.Create(fleetworkspace); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			log.Infof("Failed to create fleetworkspace %s: %v", fwName, err)
 			return obj, err
 		}
-
-		if err := patchUserAnnotations(users, obj.Name, map[string]interface{}{
-			selfWorkspaceInitAnnotation: "true",
-			userSelfFleetAnnotation:     fwName,
-		}); err != nil {
+		existing, err := h.fleetWorkspaces.Get(fwName, metav1.GetOptions{})
+		if err != nil {
 			return obj, err
 		}
+		if creator := existing.Annotations["field.cattle.io/creatorId"]; creator != obj.Name {
+			// the personal workspace was handed to someone else, do not fight over the name
+			if existing.Annotations[transferredFromAnnotation] == obj.Name {
+				reason := fmt.Sprintf("personal workspace %s was transferred to %s", fwName, creator)
+				if obj.Annotations[selfWorkspaceSkippedAnnotation] == reason {
+					return obj, nil
+				}
+				return obj, patchUserAnnotations(h.users, obj.Name, map[string]interface{}{
+					selfWorkspaceSkippedAnnotation: reason,
+				})
+			}
+			return obj, fmt.Errorf("fleetworkspace %s already exists and belongs to %q", fwName, creator)
+		}
+		if existing.DeletionTimestamp != nil {
+			return obj, fmt.Errorf("waiting for fleetworkspace %s to be removed", fwName)
+		}
+	}
 
-		return obj, nil
-	})
+	updates := map[string]interface{}{
+		selfWorkspaceInitAnnotation: "true",
+		userSelfFleetAnnotation:     fwName,
+	}
+	if _, ok := obj.Annotations[selfWorkspaceSkippedAnnotation]; ok {
+		updates[selfWorkspaceSkippedAnnotation] = nil
+	}
+	if err := patchUserAnnotations(h.users, obj.Name, updates); err != nil {
+		return obj, err
+	}
+
+	return obj, nil
 }
@@ -2,11 +2,15 @@ package controllers
 
 import (
 	"encoding/json"
+	"sync"
 	"testing"
 	"time"
 
 	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	rancherv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -124,3 +128,138 @@ func TestFindActiveWorkspaceForUser(t *testing.T) {
 		t.Fatalf("expected ws-new, got %q", ws)
 	}
 }
+
+// fakeFleetWorkspaces is a concurrency safe FleetWorkspace client. With stale set, List
+// misses everything, like a cache that has not seen the latest creates yet.
+type fakeFleetWorkspaces struct {
+	mu    sync.Mutex
+	objs  map[string]*managementv3.FleetWorkspace
+	stale bool
+}
+
+func (f *fakeFleetWorkspaces) Get(name string, opts metav1.GetOptions) (*managementv3.FleetWorkspace, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objs[name]
+	if !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{}, name)
+	}
+	return obj.DeepCopy(), nil
+}
+
+func (f *fakeFleetWorkspaces) Create(obj *managementv3.FleetWorkspace) (*managementv3.FleetWorkspace, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.objs[obj.Name]; ok {
+		return nil, errors.NewAlreadyExists(schema.GroupResource{}, obj.Name)
+	}
+	f.objs[obj.Name] = obj.DeepCopy()
+	return obj, nil
+}
+
+func (f *fakeFleetWorkspaces) List(opts metav1.ListOptions) (*managementv3.FleetWorkspaceList, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := &managementv3.FleetWorkspaceList{}
+	if f.stale {
+		return list, nil
+	}
+	for _, obj := range f.objs {
+		list.Items = append(list.Items, *obj)
+	}
+	return list, nil
+}
+
+type lockedUserPatcher struct {
+	mu      sync.Mutex
+	patches int
+}
+
+func (f *lockedUserPatcher) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*managementv3.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.patches++
+	return &managementv3.User{}, nil
+}
+
+func TestUserHandlerConcurrentReconcile(t *testing.T) {
+	fleetWorkspaces := &fakeFleetWorkspaces{objs: map[string]*managementv3.FleetWorkspace{}, stale: true}
+	patcher := &lockedUserPatcher{}
+	h := &userHandler{users: patcher, fleetWorkspaces: fleetWorkspaces, configMaps: fakeConfigMaps{}}
+	user := &managementv3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-abc"},
+		PrincipalIDs: []string{"local://u-abc"},
+		Status:       rancherv3.UserStatus{Conditions: []rancherv3.UserCondition{{Type: "InitialRolesPopulated"}}},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := h.onChange(user.Name, user.DeepCopy()); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(fleetWorkspaces.objs) != 1 {
+		t.Fatalf("expected exactly one workspace, got %d", len(fleetWorkspaces.objs))
+	}
+	if _, ok := fleetWorkspaces.objs[selfWorkspaceName("u-abc")]; !ok {
+		t.Fatalf("expected workspace %s, got %v", selfWorkspaceName("u-abc"), fleetWorkspaces.objs)
+	}
+	if patcher.patches != 10 {
+		t.Fatalf("expected every reconcile to record the workspace, got %d patches", patcher.patches)
+	}
+}
+
+func TestUserHandlerForeignWorkspace(t *testing.T) {
+	name := selfWorkspaceName("u-abc")
+	fleetWorkspaces := &fakeFleetWorkspaces{objs: map[string]*managementv3.FleetWorkspace{
+		name: {ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{"field.cattle.io/creatorId": "u-other"}}},
+	}, stale: true}
+	patcher := &lockedUserPatcher{}
+	h := &userHandler{users: patcher, fleetWorkspaces: fleetWorkspaces, configMaps: fakeConfigMaps{}}
+	user := &managementv3.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-abc"},
+		Status:     rancherv3.UserStatus{Conditions: []rancherv3.UserCondition{{Type: "InitialRolesPopulated"}}},
+	}
+
+	if _, err := h.onChange(user.Name, user); err == nil {
+		t.Fatalf("expected an error for a workspace owned by another user")
+	}
+	if patcher.patches != 0 {
+		t.Fatalf("foreign workspace must not be recorded as the user's workspace")
+	}
+}
+
+func TestUserHandlerTransferredWorkspace(t *testing.T) {
+	name := selfWorkspaceName("u-abc")
+	fleetWorkspaces := &fakeFleetWorkspaces{objs: map[string]*managementv3.FleetWorkspace{
+		name: {ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{
+			"field.cattle.io/creatorId": "u-other",
+			transferredFromAnnotation:   "u-abc",
+		}}},
+	}, stale: true}
+	patcher := &fakeUserPatcher{}
+	h := &userHandler{users: patcher, fleetWorkspaces: fleetWorkspaces, configMaps: fakeConfigMaps{}}
+	user := &managementv3.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-abc"},
+		Status:     rancherv3.UserStatus{Conditions: []rancherv3.UserCondition{{Type: "InitialRolesPopulated"}}},
+	}
+
+	if _, err := h.onChange(user.Name, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"metadata":{"annotations":{"` + selfWorkspaceSkippedAnnotation + `":"personal workspace ` + name + ` was transferred to u-other"}}}`
+	if string(patcher.lastData) != want {
+		t.Fatalf("patch = %s, want %s", patcher.lastData, want)
+	}
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/lasso/pkg/log"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// provisionedLabel marks Users the controller created for external principals.
+	provisionedLabel = "gorizond-provisioned"
+	// provisioningAnnotationPrefix followed by the requested User name records on the object
+	// waiting for it when that User was first requested, so the timeout survives restarts.
+	// Removing the annotation of a timed out User requests it again.
+	provisioningAnnotationPrefix = "gorizond-provisioning."
+	// userProvisioningRetry is how often a principal waiting for its User is re-checked.
+	userProvisioningRetry = 5 * time.Second
+	// userProvisioningTimeout is how long a requested User may take to show up in the cache.
+	userProvisioningTimeout = 2 * time.Minute
+)
+
+// userPendingError reports a User that was requested for a principal but is not in the cache yet.
+// The caller is expected to requeue after userProvisioningRetry.
+type userPendingError struct {
+	principalID string
+	userName    string
+	requested   time.Time
+}
+
+func (e *userPendingError) Error() string {
+	return fmt.Sprintf("waiting for Rancher user %s for principal %s", e.userName, e.principalID)
+}
+
+// userProvisioningTimeoutError reports a User that did not show up within userProvisioningTimeout.
+type userProvisioningTimeoutError struct {
+	principalID string
+	userName    string
+}
+
+func (e *userProvisioningTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for Rancher user %s for principal %s", userProvisioningTimeout, e.userName, e.principalID)
+}
+
+type userCreator interface {
+	Create(*managementv3.User) (*managementv3.User, error)
+}
+
+// userProvisioner resolves principals to local users, creating the Rancher User of an
+// external principal that never logged in. Nothing is granted until the User exists.
+type userProvisioner struct {
+	rancher RancherAPI
+	users   v3.UserCache
+	creator userCreator
+	now     func() time.Time
+}
+
+func newUserProvisioner(rancher RancherAPI, users v3.UserController) *userProvisioner {
+	addUserIndexers(users.Cache())
+	return &userProvisioner{
+		rancher: rancher,
+		users:   users.Cache(),
+		creator: users,
+		now:     time.Now,
+	}
+}
+
+// provisionedUserName is the deterministic name of the User requested for principalID,
+// so repeated and concurrent requests converge on one object.
+func provisionedUserName(principalID string) string {
+	sum := sha256.Sum256([]byte(principalID))
+	return "u-" + hex.EncodeToString(sum[:])[:10]
+}
+
+// provisioningAnnotation is the annotation recording when the User of principalID was first requested.
+func provisioningAnnotation(principalID string) string {
+	return provisioningAnnotationPrefix + provisionedUserName(principalID)
+}
+
+// provisioningRequested returns when the User of principalID was first requested according
+// to annotations, or the zero time.
+func provisioningRequested(annotations map[string]string, principalID string) time.Time {
+	requested, err := time.Parse(time.RFC3339, annotations[provisioningAnnotation(principalID)])
+	if err != nil {
+		return time.Time{}
+	}
+	return requested
+}
+
+// resolve returns the local user ID of a principal or reports that it is a group principal.
+// For a user principal without a User it requests one and returns a *userPendingError carrying
+// the time of the first request, which the caller persists and passes back as requested. Once
+// that is older than userProvisioningTimeout it returns a *userProvisioningTimeoutError and
+// stops requesting the User.
+func (p *userProvisioner) resolve(ctx context.Context, principalID string, requested time.Time) (string, bool, error) {
+	userID, err := userForPrincipal(p.users, principalID)
+	if err != nil {
+		return "", false, err
+	}
+	if userID != "" {
+		return userID, false, nil
+	}
+
+	name := provisionedUserName(principalID)
+	if requested.IsZero() {
+		requested = p.now()
+	} else if p.now().Sub(requested) > userProvisioningTimeout {
+		return "", false, &userProvisioningTimeoutError{principalID: principalID, userName: name}
+	}
+
+	principal, err := p.rancher.GetPrincipal(ctx, principalID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get principalID: %w", err)
+	}
+	if isGroupPrincipal(principalID, principal.PrincipalType) {
+		return "", true, nil
+	}
+
+	pending := &userPendingError{principalID: principalID, userName: name, requested: requested}
+	if existing, err := p.users.Get(name); err == nil {
+		if !slices.Contains(existing.PrincipalIDs, principalID) {
+			return "", false, fmt.Errorf("user %s already exists for principals %v", name, existing.PrincipalIDs)
+		}
+		return "", false, pending
+	} else if !errors.IsNotFound(err) {
+		return "", false, err
+	}
+
+	displayName := principal.Name
+	if displayName == "" {
+		displayName = principal.LoginName
+	}
+	_, err = p.creator.Create(&managementv3.User{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{provisionedLabel: "true"},
+		},
+		DisplayName:  displayName,
+		PrincipalIDs: []string{principalID, "local://" + name},
+	})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return "", false, fmt.Errorf("failed to create user %s for principal %s: %w", name, principalID, err)
+	}
+	if err == nil {
+		log.Infof("Requested Rancher user %s for principal %s", name, principalID)
+	}
+	return "", false, pending
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/gorizond/fleet-workspace-controller/pkg/webhook"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type membershipLister interface {
+	List(opts metav1.ListOptions) (*workspacev1.WorkspaceMembershipList, error)
+}
+
+// FleetWorkspaceValidator validates FleetWorkspace admission requests with ValidateFleetWorkspace.
+// Every replica serves the webhook, so it reads the role catalog and memberships from the API
+// instead of the caches only the leader runs.
+func FleetWorkspaceValidator(configMaps configMapGetter, memberships membershipLister) webhook.Validator {
+	return func(ctx context.Context, req *admissionv1.AdmissionRequest) (field.ErrorList, error) {
+		if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+			return nil, nil
+		}
+		obj := &managementv3.FleetWorkspace{}
+		if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+			return nil, fmt.Errorf("failed to decode fleet workspace: %w", err)
+		}
+		var old *managementv3.FleetWorkspace
+		if req.Operation == admissionv1.Update {
+			old = &managementv3.FleetWorkspace{}
+			if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+				return nil, fmt.Errorf("failed to decode old fleet workspace: %w", err)
+			}
+		}
+
+		catalog, err := loadRoleCatalog(configMaps)
+		if err != nil {
+			return nil, err
+		}
+
+		// memberships only matter when the update drops or expires the last admin annotation
+		otherAdmins := 0
+		if old != nil && annotationAdmins(old.Annotations) > 0 && permanentAdmins(obj.Annotations) == 0 {
+			otherAdmins, err = membershipAdmins(memberships, obj.Name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ValidateFleetWorkspace(old, obj, catalog, otherAdmins), nil
+	}
+}
+
+// membershipAdmins counts the WorkspaceMemberships granting admin in a workspace.
+func membershipAdmins(memberships membershipLister, workspace string) (int, error) {
+	list, err := memberships.List(metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	admins := 0
+	for _, membership := range list.Items {
+		if membership.Spec.Workspace == workspace && membership.Spec.Role == adminRole && membership.DeletionTimestamp == nil {
+			admins++
+		}
+	}
+	return admins, nil
+}
+
+type fleetWorkspaceGetter interface {
+	Get(name string, opts metav1.GetOptions) (*managementv3.FleetWorkspace, error)
+}
+
+// WorkspaceAccessRequestValidator validates WorkspaceAccessRequest admission requests with
+// ValidateWorkspaceAccessRequest, the requesting user is an admin when an annotation or a
+// WorkspaceMembership grants admin to the user or one of its groups.
+func WorkspaceAccessRequestValidator(configMaps configMapGetter, fleetWorkspaces fleetWorkspaceGetter, memberships membershipLister) webhook.Validator {
+	return func(ctx context.Context, req *admissionv1.AdmissionRequest) (field.ErrorList, error) {
+		if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+			return nil, nil
+		}
+		obj := &workspacev1.WorkspaceAccessRequest{}
+		if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+			return nil, fmt.Errorf("failed to decode workspace access request: %w", err)
+		}
+		var old *workspacev1.WorkspaceAccessRequest
+		if req.Operation == admissionv1.Update {
+			old = &workspacev1.WorkspaceAccessRequest{}
+			if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+				return nil, fmt.Errorf("failed to decode old workspace access request: %w", err)
+			}
+		}
+
+		catalog, err := loadRoleCatalog(configMaps)
+		if err != nil {
+			return nil, err
+		}
+		admin, found, err := requestingAdmin(fleetWorkspaces, memberships, obj.Spec.Workspace, req)
+		if err != nil {
+			return nil, err
+		}
+		if !found && old == nil {
+			return field.ErrorList{field.NotFound(field.NewPath("spec", "workspace"), obj.Spec.Workspace)}, nil
+		}
+		return ValidateWorkspaceAccessRequest(old, obj, req.UserInfo.Username, admin, catalog), nil
+	}
+}
+
+// WorkspaceInvitationValidator validates WorkspaceInvitation admission requests with
+// ValidateWorkspaceInvitation, only admins of the workspace may invite.
+func WorkspaceInvitationValidator(configMaps configMapGetter, fleetWorkspaces fleetWorkspaceGetter, memberships membershipLister) webhook.Validator {
+	return func(ctx context.Context, req *admissionv1.AdmissionRequest) (field.ErrorList, error) {
+		if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+			return nil, nil
+		}
+		obj := &workspacev1.WorkspaceInvitation{}
+		if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+			return nil, fmt.Errorf("failed to decode workspace invitation: %w", err)
+		}
+		if req.Operation == admissionv1.Update {
+			old := &workspacev1.WorkspaceInvitation{}
+			if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+				return nil, fmt.Errorf("failed to decode old workspace invitation: %w", err)
+			}
+			return ValidateWorkspaceInvitation(old, obj, req.UserInfo.Username, false, nil), nil
+		}
+
+		catalog, err := loadRoleCatalog(configMaps)
+		if err != nil {
+			return nil, err
+		}
+		admin, found, err := requestingAdmin(fleetWorkspaces, memberships, obj.Spec.Workspace, req)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return field.ErrorList{field.NotFound(field.NewPath("spec", "workspace"), obj.Spec.Workspace)}, nil
+		}
+		return ValidateWorkspaceInvitation(nil, obj, req.UserInfo.Username, admin, catalog), nil
+	}
+}
+
+// requestingAdmin reports whether the user behind the admission request administers the
+// workspace, found is false when the workspace does not exist.
+func requestingAdmin(fleetWorkspaces fleetWorkspaceGetter, memberships membershipLister, workspace string, req *admissionv1.AdmissionRequest) (admin, found bool, err error) {
+	ws, err := fleetWorkspaces.Get(workspace, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	list, err := memberships.List(metav1.ListOptions{})
+	if err != nil {
+		return false, true, err
+	}
+	return isWorkspaceAdmin(ws, list.Items, req.UserInfo.Username, req.UserInfo.Groups), true, nil
+}
+
+// isWorkspaceAdmin reports whether an admin annotation or membership of the workspace names
+// the user or one of its group principals.
+func isWorkspaceAdmin(ws *managementv3.FleetWorkspace, memberships []workspacev1.WorkspaceMembership, username string, groups []string) bool {
+	if _, ok := ws.Annotations["gorizond-user."+username+"."+adminRole]; ok {
+		return true
+	}
+	inGroups := map[string]bool{}
+	for _, group := range groups {
+		if _, ok := ws.Annotations["gorizond-group."+principalKeyID(group)+"."+adminRole]; ok {
+			return true
+		}
+		inGroups[group] = true
+	}
+	for _, membership := range memberships {
+		if membership.Spec.Workspace != ws.Name || membership.Spec.Role != adminRole || membership.DeletionTimestamp != nil {
+			continue
+		}
+		subject := membership.Spec.Subject
+		if subject.Kind == workspacev1.SubjectKindUser && subject.Name == username ||
+			subject.Kind != workspacev1.SubjectKindUser && inGroups[subject.Name] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"time"
+
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	workspace "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io"
+	workspacecontrollers "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io/v1"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
+	"github.com/rancher/lasso/pkg/log"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// accessRequestRetention is how long denied, rejected and expired requests stay around so
+// the requester can see the outcome.
+const accessRequestRetention = 24 * time.Hour
+
+var accessRequestProcessed = condition.Cond("Processed")
+
+// accessRequestClient is the part of the WorkspaceAccessRequest controller the handler needs.
+type accessRequestClient interface {
+	Delete(name string, options *metav1.DeleteOptions) error
+	EnqueueAfter(name string, duration time.Duration)
+}
+
+type accessRequestHandler struct {
+	requests accessRequestClient
+	granter  *membershipGranter
+	recorder record.EventRecorder
+}
+
+// InitWorkspaceAccessRequestController grants the role of approved WorkspaceAccessRequests and
+// cleans up denied and expired ones.
+func InitWorkspaceAccessRequestController(ctx context.Context, mgmt *management.Factory, ws *workspace.Factory, configMaps corecontrollers.ConfigMapCache, recorder record.EventRecorder) {
+	requests := ws.Workspace().V1().WorkspaceAccessRequest()
+	h := &accessRequestHandler{
+		requests: requests,
+		granter:  newMembershipGranter(mgmt, ws, configMaps),
+		recorder: recorder,
+	}
+	workspacecontrollers.RegisterWorkspaceAccessRequestStatusHandler(ctx, requests, accessRequestProcessed, "gorizond-workspace-access-request-controller",
+		func(obj *workspacev1.WorkspaceAccessRequest, status workspacev1.WorkspaceAccessRequestStatus) (workspacev1.WorkspaceAccessRequestStatus, error) {
+			start := time.Now()
+			status, err := h.sync(obj, status)
+			metrics.ObserveReconcile("gorizond-workspace-access-request-controller", start, err)
+			return status, err
+		})
+}
+
+func (h *accessRequestHandler) sync(obj *workspacev1.WorkspaceAccessRequest, status workspacev1.WorkspaceAccessRequestStatus) (workspacev1.WorkspaceAccessRequestStatus, error) {
+	if obj.DeletionTimestamp != nil {
+		return status, nil
+	}
+
+	switch status.Phase {
+	case workspacev1.AccessRequestPhaseApproved:
+		// approved requests stay as a record, the membership lives on the workspace
+		return status, nil
+	case workspacev1.AccessRequestPhaseDenied, workspacev1.AccessRequestPhaseExpired, workspacev1.AccessRequestPhaseRejected:
+		return status, h.cleanup(obj, status)
+	}
+
+	now := time.Now()
+	switch obj.Spec.Decision {
+	case workspacev1.AccessRequestDenied:
+		h.event(obj, corev1.EventTypeNormal, "Denied", fmt.Sprintf("%s denied access to %s", obj.Spec.DecidedBy, obj.Spec.Workspace))
+		return h.complete(obj, status, workspacev1.AccessRequestPhaseDenied, now), nil
+	case workspacev1.AccessRequestApproved:
+		requester := obj.Spec.Requester
+		if requester == "" {
+			return status, fmt.Errorf("the request names no requester")
+		}
+		if obj.Spec.DecidedBy == "" || obj.Spec.DecidedBy == requester {
+			h.event(obj, corev1.EventTypeWarning, "Rejected", fmt.Sprintf("%s cannot approve a request of %s", obj.Spec.DecidedBy, requester))
+			return h.complete(obj, status, workspacev1.AccessRequestPhaseRejected, now), nil
+		}
+		err := h.granter.grantFor(obj.Spec.DecidedBy, obj.Spec.Workspace, requester, obj.Spec.Role, obj.Spec.AccessDuration, now)
+		if goerrors.Is(err, errNotWorkspaceAdmin) {
+			h.event(obj, corev1.EventTypeWarning, "Rejected", err.Error())
+			return h.complete(obj, status, workspacev1.AccessRequestPhaseRejected, now), nil
+		}
+		if err != nil {
+			return status, err
+		}
+		h.event(obj, corev1.EventTypeNormal, "Approved", fmt.Sprintf("%s granted %s the %s role in %s",
+			obj.Spec.DecidedBy, requester, obj.Spec.Role, obj.Spec.Workspace))
+		return h.complete(obj, status, workspacev1.AccessRequestPhaseApproved, now), nil
+	case "":
+	default:
+		return status, fmt.Errorf("unknown decision %q, expected %s or %s", obj.Spec.Decision, workspacev1.AccessRequestApproved, workspacev1.AccessRequestDenied)
+	}
+
+	if obj.Spec.ExpiresAfter != nil {
+		remaining := obj.CreationTimestamp.Add(obj.Spec.ExpiresAfter.Duration).Sub(now)
+		if remaining <= 0 {
+			h.event(obj, corev1.EventTypeNormal, "Expired", "the request was not decided in time")
+			return h.complete(obj, status, workspacev1.AccessRequestPhaseExpired, now), nil
+		}
+		h.requests.EnqueueAfter(obj.Name, remaining)
+	}
+	status.Phase = workspacev1.AccessRequestPhasePending
+	return status, nil
+}
+
+// complete moves the request into a final phase and schedules the cleanup of denied and
+// expired requests.
+func (h *accessRequestHandler) complete(obj *workspacev1.WorkspaceAccessRequest, status workspacev1.WorkspaceAccessRequestStatus, phase workspacev1.AccessRequestPhase, now time.Time) workspacev1.WorkspaceAccessRequestStatus {
+	status.Phase = phase
+	status.CompletedAt = &metav1.Time{Time: now}
+	if phase != workspacev1.AccessRequestPhaseApproved {
+		h.requests.EnqueueAfter(obj.Name, accessRequestRetention)
+	}
+	log.Infof("Workspace access request %s for %s in %s: %s", obj.Name, obj.Spec.Requester, obj.Spec.Workspace, phase)
+	return status
+}
+
+// cleanup deletes a denied or expired request once it was kept for accessRequestRetention.
+func (h *accessRequestHandler) cleanup(obj *workspacev1.WorkspaceAccessRequest, status workspacev1.WorkspaceAccessRequestStatus) error {
+	if status.CompletedAt != nil {
+		if remaining := time.Until(status.CompletedAt.Add(accessRequestRetention)); remaining > 0 {
+			h.requests.EnqueueAfter(obj.Name, remaining)
+			return nil
+		}
+	}
+	if err := h.requests.Delete(obj.Name, nil); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (h *accessRequestHandler) event(obj *workspacev1.WorkspaceAccessRequest, eventType, reason, message string) {
+	if h.recorder != nil {
+		h.recorder.Event(obj, eventType, reason, message)
+	}
+}
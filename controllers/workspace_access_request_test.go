@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeAccessRequests struct {
+	deleted  []string
+	enqueued map[string]time.Duration
+}
+
+func (f *fakeAccessRequests) Delete(name string, options *metav1.DeleteOptions) error {
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+func (f *fakeAccessRequests) EnqueueAfter(name string, duration time.Duration) {
+	f.enqueued[name] = duration
+}
+
+func TestAccessRequestSync(t *testing.T) {
+	users := newFakeCache(&managementv3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-contractor"},
+		PrincipalIDs: []string{"local://u-contractor", "github_user://9"},
+	})
+	workspaces := newFakeCache(&managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Annotations: map[string]string{
+		"gorizond-user.u-admin.admin": "local://u-admin",
+		"gorizond-user.u-member.view": "local://u-member",
+	}}})
+	request := func(requester string, decision workspacev1.AccessRequestDecision, age time.Duration) *workspacev1.WorkspaceAccessRequest {
+		return &workspacev1.WorkspaceAccessRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "request",
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			},
+			Spec: workspacev1.WorkspaceAccessRequestSpec{
+				Workspace:      "workspace-a",
+				Requester:      requester,
+				Role:           "view",
+				ExpiresAfter:   &metav1.Duration{Duration: time.Hour},
+				AccessDuration: &metav1.Duration{Duration: 7 * 24 * time.Hour},
+				Decision:       decision,
+				DecidedBy:      "u-admin",
+			},
+		}
+	}
+	byMember := request("u-contractor", workspacev1.AccessRequestApproved, time.Minute)
+	byMember.Spec.DecidedBy = "u-member"
+	completed := func(phase workspacev1.AccessRequestPhase, age time.Duration) workspacev1.WorkspaceAccessRequestStatus {
+		return workspacev1.WorkspaceAccessRequestStatus{Phase: phase, CompletedAt: &metav1.Time{Time: time.Now().Add(-age)}}
+	}
+
+	tests := []struct {
+		name        string
+		obj         *workspacev1.WorkspaceAccessRequest
+		status      workspacev1.WorkspaceAccessRequestStatus
+		wantPhase   workspacev1.AccessRequestPhase
+		wantPatch   bool
+		wantDeleted bool
+		wantErr     string
+	}{
+		{name: "pending", obj: request("u-contractor", "", time.Minute), wantPhase: workspacev1.AccessRequestPhasePending},
+		{name: "expired", obj: request("u-contractor", "", 2*time.Hour), wantPhase: workspacev1.AccessRequestPhaseExpired},
+		{name: "denied", obj: request("u-contractor", workspacev1.AccessRequestDenied, time.Minute), wantPhase: workspacev1.AccessRequestPhaseDenied},
+		{name: "approved", obj: request("u-contractor", workspacev1.AccessRequestApproved, time.Minute), wantPhase: workspacev1.AccessRequestPhaseApproved, wantPatch: true},
+		{name: "already a member", obj: request("u-member", workspacev1.AccessRequestApproved, time.Minute), wantPhase: workspacev1.AccessRequestPhaseApproved},
+		{name: "self approved", obj: request("u-admin", workspacev1.AccessRequestApproved, time.Minute), wantPhase: workspacev1.AccessRequestPhaseRejected},
+		{name: "approved by a non admin", obj: byMember, wantPhase: workspacev1.AccessRequestPhaseRejected},
+		{name: "unknown requester", obj: request("u-gone", workspacev1.AccessRequestApproved, time.Minute), wantErr: "not found"},
+		{
+			name:      "approved is not granted twice",
+			obj:       request("u-contractor", workspacev1.AccessRequestApproved, time.Minute),
+			status:    completed(workspacev1.AccessRequestPhaseApproved, time.Minute),
+			wantPhase: workspacev1.AccessRequestPhaseApproved,
+		},
+		{
+			name:      "denied is kept for a while",
+			obj:       request("u-contractor", workspacev1.AccessRequestDenied, time.Hour),
+			status:    completed(workspacev1.AccessRequestPhaseDenied, time.Hour),
+			wantPhase: workspacev1.AccessRequestPhaseDenied,
+		},
+		{
+			name:        "rejected is cleaned up",
+			obj:         byMember,
+			status:      completed(workspacev1.AccessRequestPhaseRejected, 25*time.Hour),
+			wantPhase:   workspacev1.AccessRequestPhaseRejected,
+			wantDeleted: true,
+		},
+		{
+			name:        "expired is cleaned up",
+			obj:         request("u-contractor", "", 48*time.Hour),
+			status:      completed(workspacev1.AccessRequestPhaseExpired, 25*time.Hour),
+			wantPhase:   workspacev1.AccessRequestPhaseExpired,
+			wantDeleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := &fakeAccessRequests{enqueued: map[string]time.Duration{}}
+			patcher := &fakeFleetWorkspacePatcher{patches: map[string][]byte{}}
+			h := &accessRequestHandler{requests: requests, granter: &membershipGranter{fleetWorkspaces: workspaces, workspaceClient: patcher, users: users, configMaps: fakeConfigMaps{}}}
+
+			status, err := h.sync(tt.obj, tt.status)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", status.Phase, tt.wantPhase)
+			}
+			if (len(requests.deleted) > 0) != tt.wantDeleted {
+				t.Errorf("deleted = %v, want deleted %v", requests.deleted, tt.wantDeleted)
+			}
+			data, patched := patcher.patches["workspace-a"]
+			if patched != tt.wantPatch {
+				t.Fatalf("patched = %v, want %v", patched, tt.wantPatch)
+			}
+			if !patched {
+				return
+			}
+			var patch struct {
+				Metadata struct {
+					Annotations map[string]string `json:"annotations"`
+				} `json:"metadata"`
+			}
+			if err := json.Unmarshal(data, &patch); err != nil {
+				t.Fatalf("invalid patch %s: %v", data, err)
+			}
+			if patch.Metadata.Annotations["gorizond-user.u-contractor.view"] != "github_user://9" {
+				t.Errorf("patch = %s, want the membership annotation", data)
+			}
+			if _, err := time.Parse(time.RFC3339, patch.Metadata.Annotations[membershipExpiryPrefix+"gorizond-user.u-contractor.view"]); err != nil {
+				t.Errorf("patch = %s, want the access duration as expiry", data)
+			}
+		})
+	}
+}
+
+func TestValidateWorkspaceAccessRequest(t *testing.T) {
+	request := func(requester string, decision workspacev1.AccessRequestDecision, decidedBy string) *workspacev1.WorkspaceAccessRequest {
+		return &workspacev1.WorkspaceAccessRequest{Spec: workspacev1.WorkspaceAccessRequestSpec{
+			Workspace: "workspace-a",
+			Requester: requester,
+			Role:      "view",
+			Decision:  decision,
+			DecidedBy: decidedBy,
+		}}
+	}
+	changedRole := request("u-contractor", "", "")
+	changedRole.Spec.Role = "admin"
+
+	tests := []struct {
+		name     string
+		old      *workspacev1.WorkspaceAccessRequest
+		obj      *workspacev1.WorkspaceAccessRequest
+		username string
+		admin    bool
+		wantErr  string
+	}{
+		{name: "request", obj: request("u-contractor", "", ""), username: "u-contractor"},
+		{name: "no requester", obj: request("", "", ""), username: "u-contractor", wantErr: "Required value"},
+		{name: "for someone else", obj: request("u-other", "", ""), username: "u-contractor", wantErr: "someone else"},
+		{name: "admin requests for someone else", obj: request("u-other", "", ""), username: "u-admin", admin: true},
+		{name: "unknown role", obj: &workspacev1.WorkspaceAccessRequest{Spec: workspacev1.WorkspaceAccessRequestSpec{Workspace: "workspace-a", Requester: "u-contractor", Role: "owner"}}, username: "u-contractor", wantErr: "Unsupported value"},
+		{name: "self approved on create", obj: request("u-contractor", workspacev1.AccessRequestApproved, "u-contractor"), username: "u-contractor", wantErr: "only workspace admins"},
+		{
+			name:     "admin approves",
+			old:      request("u-contractor", "", ""),
+			obj:      request("u-contractor", workspacev1.AccessRequestApproved, "u-admin"),
+			username: "u-admin",
+			admin:    true,
+		},
+		{
+			name:     "non admin approves",
+			old:      request("u-contractor", "", ""),
+			obj:      request("u-contractor", workspacev1.AccessRequestApproved, "u-contractor"),
+			username: "u-contractor",
+			wantErr:  "only workspace admins",
+		},
+		{
+			name:     "decided by someone else",
+			old:      request("u-contractor", "", ""),
+			obj:      request("u-contractor", workspacev1.AccessRequestDenied, "u-boss"),
+			username: "u-admin",
+			admin:    true,
+			wantErr:  "deciding user",
+		},
+		{
+			name:     "decision is final",
+			old:      request("u-contractor", workspacev1.AccessRequestDenied, "u-admin"),
+			obj:      request("u-contractor", workspacev1.AccessRequestApproved, "u-admin"),
+			username: "u-admin",
+			admin:    true,
+			wantErr:  "already decided",
+		},
+		{
+			name:     "role is immutable",
+			old:      request("u-contractor", "", ""),
+			obj:      changedRole,
+			username: "u-contractor",
+			wantErr:  "only the decision",
+		},
+		{
+			name:     "unrelated update of a decided request",
+			old:      request("u-contractor", workspacev1.AccessRequestApproved, "u-admin"),
+			obj:      request("u-contractor", workspacev1.AccessRequestApproved, "u-admin"),
+			username: "u-contractor",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateWorkspaceAccessRequest(tt.old, tt.obj, tt.username, tt.admin, defaultRoleCatalog())
+			if tt.wantErr == "" {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if !strings.Contains(errs.ToAggregate().Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
+func TestIsWorkspaceAdmin(t *testing.T) {
+	ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Annotations: map[string]string{
+		"gorizond-user.u-admin.admin": "local://u-admin",
+		"gorizond-group.42.admin":     "github_org://42",
+		"gorizond-user.u-viewer.view": "local://u-viewer",
+	}}}
+	memberships := []workspacev1.WorkspaceMembership{{Spec: workspacev1.WorkspaceMembershipSpec{
+		Workspace: "workspace-a",
+		Role:      adminRole,
+		Subject:   workspacev1.Subject{Kind: workspacev1.SubjectKindUser, Name: "u-member"},
+	}}}
+
+	tests := []struct {
+		username string
+		groups   []string
+		want     bool
+	}{
+		{username: "u-admin", want: true},
+		{username: "u-viewer"},
+		{username: "u-ops", groups: []string{"github_org://42"}, want: true},
+		{username: "u-member", want: true},
+		{username: "u-nobody", groups: []string{"u-member"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			if got := isWorkspaceAdmin(ws, memberships, tt.username, tt.groups); got != tt.want {
+				t.Fatalf("isWorkspaceAdmin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	v3 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	workspace "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io"
+	workspacecontrollers "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io/v1"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
+	"github.com/rancher/lasso/pkg/log"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// invitationByInviteeIndex indexes WorkspaceInvitations by inviteeKey.
+	invitationByInviteeIndex = "gorizond-invitation-by-invitee"
+
+	defaultInvitationTTL = 7 * 24 * time.Hour
+
+	// invitationRetention is how long expired and rejected invitations stay around so the
+	// inviter can see the outcome.
+	invitationRetention = 24 * time.Hour
+)
+
+var invitationProcessed = condition.Cond("Processed")
+
+// getInvitationTTL is how long an invitation without expiresAfter waits for the invitee.
+func getInvitationTTL() time.Duration {
+	if env := os.Getenv("INVITATION_TTL"); env != "" {
+		ttl, err := time.ParseDuration(env)
+		if err == nil && ttl > 0 {
+			return ttl
+		}
+		log.Errorf("Ignoring invalid INVITATION_TTL %q, using %s", env, defaultInvitationTTL)
+	}
+	return defaultInvitationTTL
+}
+
+// inviteeKey is the index key of an invitee. Login names and email addresses are compared
+// case-insensitively, principal IDs as they are.
+func inviteeKey(kind workspacev1.InviteeKind, value string) string {
+	if kind != workspacev1.InviteeKindPrincipal {
+		value = strings.ToLower(value)
+	}
+	return string(kind) + ":" + value
+}
+
+// userInviteeKeys returns the invitee keys a user answers to: its principal IDs, its local
+// login name and the login names and email addresses its auth providers reported.
+// attribute is nil for users who never logged in.
+func userInviteeKeys(user *managementv3.User, attribute *managementv3.UserAttribute) []string {
+	seen := map[string]bool{}
+	var keys []string
+	add := func(kind workspacev1.InviteeKind, value string) {
+		if value == "" {
+			return
+		}
+		key := inviteeKey(kind, value)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	login := func(value string) {
+		add(workspacev1.InviteeKindLogin, value)
+		if strings.Contains(value, "@") {
+			add(workspacev1.InviteeKindEmail, value)
+		}
+	}
+
+	for _, id := range user.PrincipalIDs {
+		add(workspacev1.InviteeKindPrincipal, id)
+	}
+	login(user.Username)
+	if attribute != nil {
+		providers := make([]string, 0, len(attribute.ExtraByProvider))
+		for provider := range attribute.ExtraByProvider {
+			providers = append(providers, provider)
+		}
+		sort.Strings(providers)
+		for _, provider := range providers {
+			extra := attribute.ExtraByProvider[provider]
+			for _, value := range extra["username"] {
+				login(value)
+			}
+			for _, value := range extra["email"] {
+				add(workspacev1.InviteeKindEmail, value)
+			}
+		}
+	}
+	return keys
+}
+
+// isSystemUser reports whether the user is one of Rancher's system accounts, which are
+// never invited.
+func isSystemUser(user *managementv3.User) bool {
+	for _, id := range user.PrincipalIDs {
+		if strings.HasPrefix(id, "system://") {
+			return true
+		}
+	}
+	return false
+}
+
+// invitationClient is the part of the WorkspaceInvitation controller the handlers need.
+type invitationClient interface {
+	Delete(name string, options *metav1.DeleteOptions) error
+	Enqueue(name string)
+	EnqueueAfter(name string, duration time.Duration)
+}
+
+// invitationMatcher lets the user controller hand new users to the invitations naming them.
+type invitationMatcher struct {
+	invitations    workspacecontrollers.WorkspaceInvitationCache
+	client         invitationClient
+	userAttributes userAttributeGetter
+}
+
+// enqueueFor enqueues the pending invitations matching the user, the invitation controller
+// then binds them.
+func (m *invitationMatcher) enqueueFor(user *managementv3.User) error {
+	var attribute *managementv3.UserAttribute
+	if m.userAttributes != nil {
+		if found, err := m.userAttributes.Get(user.Name); err == nil {
+			attribute = found
+		} else if !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	for _, key := range userInviteeKeys(user, attribute) {
+		invitations, err := m.invitations.GetByIndex(invitationByInviteeIndex, key)
+		if err != nil {
+			return err
+		}
+		for _, invitation := range invitations {
+			if invitation.Status.Phase == "" || invitation.Status.Phase == workspacev1.InvitationPhasePending {
+				m.client.Enqueue(invitation.Name)
+			}
+		}
+	}
+	return nil
+}
+
+type invitationHandler struct {
+	invitations    invitationClient
+	users          v3.UserCache
+	userAttributes userAttributeGetter
+	granter        *membershipGranter
+	recorder       record.EventRecorder
+}
+
+// InitWorkspaceInvitationController binds pending WorkspaceInvitations to the users they name,
+// expires the ones nobody accepted in time and returns the matcher the user controller uses
+// to bind users who show up later.
+func InitWorkspaceInvitationController(ctx context.Context, mgmt *management.Factory, ws *workspace.Factory, configMaps corecontrollers.ConfigMapCache, recorder record.EventRecorder) *invitationMatcher {
+	invitations := ws.Workspace().V1().WorkspaceInvitation()
+	invitations.Cache().AddIndexer(invitationByInviteeIndex, func(obj *workspacev1.WorkspaceInvitation) ([]string, error) {
+		return []string{inviteeKey(obj.Spec.Invitee.Kind, obj.Spec.Invitee.Value)}, nil
+	})
+	users := mgmt.Management().V3().User().Cache()
+	addUserIndexers(users)
+	userAttributes := mgmt.Management().V3().UserAttribute().Cache()
+
+	h := &invitationHandler{
+		invitations:    invitations,
+		users:          users,
+		userAttributes: userAttributes,
+		granter:        newMembershipGranter(mgmt, ws, configMaps),
+		recorder:       recorder,
+	}
+	workspacecontrollers.RegisterWorkspaceInvitationStatusHandler(ctx, invitations, invitationProcessed, "gorizond-workspace-invitation-controller",
+		func(obj *workspacev1.WorkspaceInvitation, status workspacev1.WorkspaceInvitationStatus) (workspacev1.WorkspaceInvitationStatus, error) {
+			start := time.Now()
+			status, err := h.sync(obj, status)
+			metrics.ObserveReconcile("gorizond-workspace-invitation-controller", start, err)
+			return status, err
+		})
+
+	return &invitationMatcher{
+		invitations:    invitations.Cache(),
+		client:         invitations,
+		userAttributes: userAttributes,
+	}
+}
+
+func (h *invitationHandler) sync(obj *workspacev1.WorkspaceInvitation, status workspacev1.WorkspaceInvitationStatus) (workspacev1.WorkspaceInvitationStatus, error) {
+	if obj.DeletionTimestamp != nil {
+		return status, nil
+	}
+
+	switch status.Phase {
+	case workspacev1.InvitationPhaseAccepted:
+		// accepted invitations stay as a record, the membership lives on the workspace
+		return status, nil
+	case workspacev1.InvitationPhaseExpired, workspacev1.InvitationPhaseRejected:
+		return status, h.cleanup(obj, status)
+	}
+
+	now := time.Now()
+	ttl := getInvitationTTL()
+	if obj.Spec.ExpiresAfter != nil {
+		ttl = obj.Spec.ExpiresAfter.Duration
+	}
+	expiresAt := obj.CreationTimestamp.Add(ttl)
+	if !now.Before(expiresAt) {
+		h.event(obj, corev1.EventTypeNormal, "Expired", fmt.Sprintf("nobody matching %s %s showed up in time", obj.Spec.Invitee.Kind, obj.Spec.Invitee.Value))
+		return h.complete(obj, status, workspacev1.InvitationPhaseExpired, now), nil
+	}
+
+	userID, err := h.invitee(obj)
+	if err != nil {
+		return status, err
+	}
+	if userID != "" {
+		// invitations grant a membership that lasts, expiresAfter only bounds the wait
+		err := h.granter.grantFor(obj.Spec.InvitedBy, obj.Spec.Workspace, userID, obj.Spec.Role, nil, now)
+		if goerrors.Is(err, errNotWorkspaceAdmin) {
+			h.event(obj, corev1.EventTypeWarning, "Rejected", err.Error())
+			return h.complete(obj, status, workspacev1.InvitationPhaseRejected, now), nil
+		}
+		if err != nil {
+			return status, err
+		}
+		h.event(obj, corev1.EventTypeNormal, "Accepted", fmt.Sprintf("%s joined %s as %s", userID, obj.Spec.Workspace, obj.Spec.Role))
+		status.AcceptedBy = userID
+		return h.complete(obj, status, workspacev1.InvitationPhaseAccepted, now), nil
+	}
+
+	h.invitations.EnqueueAfter(obj.Name, expiresAt.Sub(now))
+	status.Phase = workspacev1.InvitationPhasePending
+	status.ExpiresAt = &metav1.Time{Time: expiresAt}
+	return status, nil
+}
+
+// invitee returns the user the invitation names, empty while no such user exists. Several
+// matching users are an error, the invitation would otherwise pick one at random.
+func (h *invitationHandler) invitee(obj *workspacev1.WorkspaceInvitation) (string, error) {
+	if obj.Spec.Invitee.Kind == workspacev1.InviteeKindPrincipal {
+		return userForPrincipal(h.users, obj.Spec.Invitee.Value)
+	}
+
+	want := inviteeKey(obj.Spec.Invitee.Kind, obj.Spec.Invitee.Value)
+	users, err := h.users.List(labels.Everything())
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, user := range users {
+		if user.DeletionTimestamp != nil || isSystemUser(user) {
+			continue
+		}
+		var attribute *managementv3.UserAttribute
+		if h.userAttributes != nil {
+			if found, err := h.userAttributes.Get(user.Name); err == nil {
+				attribute = found
+			}
+		}
+		for _, key := range userInviteeKeys(user, attribute) {
+			if key == want {
+				matches = append(matches, user.Name)
+				break
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", nil
+	case 1:
+		return matches[0], nil
+	}
+	sort.Strings(matches)
+	return "", fmt.Errorf("%s %s matches several users: %s", obj.Spec.Invitee.Kind, obj.Spec.Invitee.Value, strings.Join(matches, ", "))
+}
+
+// complete moves the invitation into a final phase and schedules the cleanup of expired and
+// rejected invitations.
+func (h *invitationHandler) complete(obj *workspacev1.WorkspaceInvitation, status workspacev1.WorkspaceInvitationStatus, phase workspacev1.InvitationPhase, now time.Time) workspacev1.WorkspaceInvitationStatus {
+	status.Phase = phase
+	status.ExpiresAt = nil
+	status.CompletedAt = &metav1.Time{Time: now}
+	if phase != workspacev1.InvitationPhaseAccepted {
+		h.invitations.EnqueueAfter(obj.Name, invitationRetention)
+	}
+	log.Infof("Workspace invitation %s for %s %s in %s: %s", obj.Name, obj.Spec.Invitee.Kind, obj.Spec.Invitee.Value, obj.Spec.Workspace, phase)
+	return status
+}
+
+// cleanup deletes an expired or rejected invitation once it was kept for invitationRetention.
+func (h *invitationHandler) cleanup(obj *workspacev1.WorkspaceInvitation, status workspacev1.WorkspaceInvitationStatus) error {
+	if status.CompletedAt != nil {
+		if remaining := time.Until(status.CompletedAt.Add(invitationRetention)); remaining > 0 {
+			h.invitations.EnqueueAfter(obj.Name, remaining)
+			return nil
+		}
+	}
+	if err := h.invitations.Delete(obj.Name, nil); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (h *invitationHandler) event(obj *workspacev1.WorkspaceInvitation, eventType, reason, message string) {
+	if h.recorder != nil {
+		h.recorder.Event(obj, eventType, reason, message)
+	}
+}
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeInvitations struct {
+	deleted  []string
+	queued   []string
+	enqueued map[string]time.Duration
+}
+
+func (f *fakeInvitations) Delete(name string, options *metav1.DeleteOptions) error {
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+func (f *fakeInvitations) Enqueue(name string) {
+	f.queued = append(f.queued, name)
+}
+
+func (f *fakeInvitations) EnqueueAfter(name string, duration time.Duration) {
+	f.enqueued[name] = duration
+}
+
+func TestUserInviteeKeys(t *testing.T) {
+	user := &managementv3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-abc"},
+		Username:     "Alice",
+		PrincipalIDs: []string{"local://u-abc", "github_user://42"},
+	}
+	attribute := &managementv3.UserAttribute{ExtraByProvider: map[string]map[string][]string{
+		"github":   {"username": {"alice-gh"}},
+		"keycloak": {"username": {"Alice@Example.com"}, "email": {"alice@example.com"}},
+	}}
+
+	got := userInviteeKeys(user, attribute)
+	want := []string{
+		"Principal:local://u-abc",
+		"Principal:github_user://42",
+		"Login:alice",
+		"Login:alice-gh",
+		"Login:alice@example.com",
+		"Email:alice@example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("userInviteeKeys() = %v, want %v", got, want)
+	}
+	if got := userInviteeKeys(user, nil); len(got) != 3 {
+		t.Fatalf("userInviteeKeys() without attribute = %v, want principals and login", got)
+	}
+}
+
+func TestInvitationSync(t *testing.T) {
+	users := newFakeCache(
+		&managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-alice"}, PrincipalIDs: []string{"local://u-alice", "github_user://42"}},
+		&managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-bob"}, Username: "bob", PrincipalIDs: []string{"local://u-bob"}},
+		&managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-bob2"}, Username: "BOB", PrincipalIDs: []string{"local://u-bob2"}},
+		&managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-system"}, Username: "carol", PrincipalIDs: []string{"system://local"}},
+	)
+	users.AddIndexer(userByPrincipalIndex, userPrincipalIndexer)
+	attributes := newFakeCache(&managementv3.UserAttribute{
+		ObjectMeta:      metav1.ObjectMeta{Name: "u-alice"},
+		ExtraByProvider: map[string]map[string][]string{"keycloak": {"email": {"alice@example.com"}}},
+	})
+	workspaces := newFakeCache(&managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Annotations: map[string]string{
+		"gorizond-user.u-admin.admin": "local://u-admin",
+		"gorizond-user.u-viewer.view": "local://u-viewer",
+	}}})
+
+	invitation := func(kind workspacev1.InviteeKind, value string, age time.Duration) *workspacev1.WorkspaceInvitation {
+		return &workspacev1.WorkspaceInvitation{
+			ObjectMeta: metav1.ObjectMeta{Name: "invitation", CreationTimestamp: metav1.NewTime(time.Now().Add(-age))},
+			Spec: workspacev1.WorkspaceInvitationSpec{
+				Workspace:    "workspace-a",
+				Invitee:      workspacev1.Invitee{Kind: kind, Value: value},
+				Role:         "view",
+				InvitedBy:    "u-admin",
+				ExpiresAfter: &metav1.Duration{Duration: time.Hour},
+			},
+		}
+	}
+	byViewer := invitation(workspacev1.InviteeKindPrincipal, "github_user://42", time.Minute)
+	byViewer.Spec.InvitedBy = "u-viewer"
+
+	tests := []struct {
+		name         string
+		obj          *workspacev1.WorkspaceInvitation
+		status       workspacev1.WorkspaceInvitationStatus
+		wantPhase    workspacev1.InvitationPhase
+		wantAccepted string
+		wantDeleted  bool
+		wantErr      string
+	}{
+		{name: "nobody yet", obj: invitation(workspacev1.InviteeKindLogin, "dave", time.Minute), wantPhase: workspacev1.InvitationPhasePending},
+		{name: "by principal", obj: invitation(workspacev1.InviteeKindPrincipal, "github_user://42", time.Minute), wantPhase: workspacev1.InvitationPhaseAccepted, wantAccepted: "u-alice"},
+		{name: "by email", obj: invitation(workspacev1.InviteeKindEmail, "Alice@Example.com", time.Minute), wantPhase: workspacev1.InvitationPhaseAccepted, wantAccepted: "u-alice"},
+		{name: "inviter is not an admin", obj: byViewer, wantPhase: workspacev1.InvitationPhaseRejected},
+		{name: "system users are not invited", obj: invitation(workspacev1.InviteeKindLogin, "carol", time.Minute), wantPhase: workspacev1.InvitationPhasePending},
+		{name: "ambiguous login", obj: invitation(workspacev1.InviteeKindLogin, "bob", time.Minute), wantErr: "several users"},
+		{name: "expired", obj: invitation(workspacev1.InviteeKindLogin, "dave", 2*time.Hour), wantPhase: workspacev1.InvitationPhaseExpired},
+		{
+			name:      "accepted is not granted twice",
+			obj:       invitation(workspacev1.InviteeKindPrincipal, "github_user://42", time.Minute),
+			status:    workspacev1.WorkspaceInvitationStatus{Phase: workspacev1.InvitationPhaseAccepted, AcceptedBy: "u-alice"},
+			wantPhase: workspacev1.InvitationPhaseAccepted, wantAccepted: "u-alice",
+		},
+		{
+			name:        "rejected is cleaned up",
+			obj:         byViewer,
+			status:      workspacev1.WorkspaceInvitationStatus{Phase: workspacev1.InvitationPhaseRejected, CompletedAt: &metav1.Time{Time: time.Now().Add(-25 * time.Hour)}},
+			wantPhase:   workspacev1.InvitationPhaseRejected,
+			wantDeleted: true,
+		},
+		{
+			name:        "expired is cleaned up",
+			obj:         invitation(workspacev1.InviteeKindLogin, "dave", 48*time.Hour),
+			status:      workspacev1.WorkspaceInvitationStatus{Phase: workspacev1.InvitationPhaseExpired, CompletedAt: &metav1.Time{Time: time.Now().Add(-25 * time.Hour)}},
+			wantPhase:   workspacev1.InvitationPhaseExpired,
+			wantDeleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invitations := &fakeInvitations{enqueued: map[string]time.Duration{}}
+			patcher := &fakeFleetWorkspacePatcher{patches: map[string][]byte{}}
+			h := &invitationHandler{
+				invitations:    invitations,
+				users:          users,
+				userAttributes: attributes,
+				granter:        &membershipGranter{fleetWorkspaces: workspaces, workspaceClient: patcher, users: users, configMaps: fakeConfigMaps{}},
+			}
+
+			status, err := h.sync(tt.obj, tt.status)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if status.Phase != tt.wantPhase || status.AcceptedBy != tt.wantAccepted {
+				t.Errorf("status = %s/%q, want %s/%q", status.Phase, status.AcceptedBy, tt.wantPhase, tt.wantAccepted)
+			}
+			if (len(invitations.deleted) > 0) != tt.wantDeleted {
+				t.Errorf("deleted = %v, want deleted %v", invitations.deleted, tt.wantDeleted)
+			}
+			// only a fresh acceptance patches the workspace
+			_, patched := patcher.patches["workspace-a"]
+			if wantPatch := tt.wantAccepted != "" && tt.status.Phase == ""; patched != wantPatch {
+				t.Errorf("patched = %v, want %v", patched, wantPatch)
+			}
+			if status.Phase == workspacev1.InvitationPhasePending && (status.ExpiresAt == nil || invitations.enqueued["invitation"] <= 0) {
+				t.Errorf("pending invitation without expiry: %+v, enqueued %v", status, invitations.enqueued)
+			}
+		})
+	}
+}
+
+func TestInvitationMatcherEnqueueFor(t *testing.T) {
+	pending := func(name string, kind workspacev1.InviteeKind, value string, phase workspacev1.InvitationPhase) *workspacev1.WorkspaceInvitation {
+		return &workspacev1.WorkspaceInvitation{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       workspacev1.WorkspaceInvitationSpec{Invitee: workspacev1.Invitee{Kind: kind, Value: value}},
+			Status:     workspacev1.WorkspaceInvitationStatus{Phase: phase},
+		}
+	}
+	invitations := newFakeCache(
+		pending("by-login", workspacev1.InviteeKindLogin, "Dave", workspacev1.InvitationPhasePending),
+		pending("by-principal", workspacev1.InviteeKindPrincipal, "github_user://7", ""),
+		pending("accepted", workspacev1.InviteeKindLogin, "dave", workspacev1.InvitationPhaseAccepted),
+		pending("someone-else", workspacev1.InviteeKindLogin, "erin", workspacev1.InvitationPhasePending),
+	)
+	invitations.AddIndexer(invitationByInviteeIndex, func(obj *workspacev1.WorkspaceInvitation) ([]string, error) {
+		return []string{inviteeKey(obj.Spec.Invitee.Kind, obj.Spec.Invitee.Value)}, nil
+	})
+	client := &fakeInvitations{enqueued: map[string]time.Duration{}}
+	m := &invitationMatcher{invitations: invitations, client: client, userAttributes: newFakeCache[*managementv3.UserAttribute]()}
+
+	user := &managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-dave"}, Username: "dave", PrincipalIDs: []string{"local://u-dave", "github_user://7"}}
+	if err := m.enqueueFor(user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"by-principal", "by-login"}; !reflect.DeepEqual(client.queued, want) {
+		t.Fatalf("enqueued = %v, want %v", client.queued, want)
+	}
+}
+
+func TestValidateWorkspaceInvitation(t *testing.T) {
+	invitation := func(kind workspacev1.InviteeKind, value, role string) *workspacev1.WorkspaceInvitation {
+		return &workspacev1.WorkspaceInvitation{Spec: workspacev1.WorkspaceInvitationSpec{
+			Workspace: "workspace-a",
+			Invitee:   workspacev1.Invitee{Kind: kind, Value: value},
+			Role:      role,
+			InvitedBy: "u-admin",
+		}}
+	}
+	negative := invitation(workspacev1.InviteeKindLogin, "dave", "view")
+	negative.Spec.ExpiresAfter = &metav1.Duration{Duration: -time.Hour}
+
+	tests := []struct {
+		name     string
+		old      *workspacev1.WorkspaceInvitation
+		obj      *workspacev1.WorkspaceInvitation
+		username string
+		admin    bool
+		wantErr  string
+	}{
+		{name: "login", obj: invitation(workspacev1.InviteeKindLogin, "dave", "view"), admin: true},
+		{name: "email", obj: invitation(workspacev1.InviteeKindEmail, "dave@example.com", "editor"), admin: true},
+		{name: "in someone else's name", obj: invitation(workspacev1.InviteeKindLogin, "dave", "view"), username: "u-other", admin: true, wantErr: "inviting user"},
+		{name: "not an admin", obj: invitation(workspacev1.InviteeKindLogin, "dave", "view"), wantErr: "only workspace admins"},
+		{name: "not an email", obj: invitation(workspacev1.InviteeKindEmail, "dave", "view"), admin: true, wantErr: "email address"},
+		{name: "unknown kind", obj: invitation("Phone", "123", "view"), admin: true, wantErr: "Unsupported value"},
+		{name: "no invitee", obj: invitation(workspacev1.InviteeKindLogin, "", "view"), admin: true, wantErr: "Required value"},
+		{name: "unknown role", obj: invitation(workspacev1.InviteeKindLogin, "dave", "owner"), admin: true, wantErr: "Unsupported value"},
+		{name: "negative expiry", obj: negative, admin: true, wantErr: "must be positive"},
+		{
+			name:    "spec is immutable",
+			old:     invitation(workspacev1.InviteeKindLogin, "dave", "view"),
+			obj:     invitation(workspacev1.InviteeKindLogin, "dave", "admin"),
+			wantErr: "cannot change",
+		},
+		{name: "unchanged update", old: invitation(workspacev1.InviteeKindLogin, "dave", "view"), obj: invitation(workspacev1.InviteeKindLogin, "dave", "view")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username := tt.username
+			if username == "" {
+				username = "u-admin"
+			}
+			errs := ValidateWorkspaceInvitation(tt.old, tt.obj, username, tt.admin, defaultRoleCatalog())
+			if tt.wantErr == "" {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if !strings.Contains(errs.ToAggregate().Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"strings"
+
+	v3 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var (
+	workspacesManagedDesc = prometheus.NewDesc("gorizond_workspaces_managed",
+		"Number of FleetWorkspaces managed by the controller.", nil, nil)
+	workspaceBindingsDesc = prometheus.NewDesc("gorizond_workspace_bindings",
+		"Number of GlobalRoleBindings per workspace.", []string{"workspace"}, nil)
+	pendingPrincipalsDesc = prometheus.NewDesc("gorizond_pending_principal_resolutions",
+		"Number of gorizond-principal. annotations per workspace not resolved yet.", []string{"workspace"}, nil)
+)
+
+// workspaceCollector computes the workspace gauges from the informer caches on every scrape,
+// so deleted workspaces never leave stale series behind.
+type workspaceCollector struct {
+	fleetWorkspaces    v3.FleetWorkspaceCache
+	globalRoleBindings v3.GlobalRoleBindingCache
+}
+
+func (c *workspaceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workspacesManagedDesc
+	ch <- workspaceBindingsDesc
+	ch <- pendingPrincipalsDesc
+}
+
+func (c *workspaceCollector) Collect(ch chan<- prometheus.Metric) {
+	workspaces, err := c.fleetWorkspaces.List(labels.Everything())
+	if err != nil {
+		return
+	}
+	bindings := map[string]int{}
+	if grbs, err := c.globalRoleBindings.List(labels.Everything()); err == nil {
+		for _, grb := range grbs {
+			if ws := grb.Labels["fleet"]; ws != "" {
+				bindings[ws]++
+			}
+		}
+	}
+
+	managed := 0
+	for _, ws := range workspaces {
+		if ws.DeletionTimestamp != nil || !strings.HasPrefix(ws.Name, workspacePrefix) {
+			continue
+		}
+		managed++
+		pending := 0
+		for k := range ws.Annotations {
+			if strings.HasPrefix(k, "gorizond-principal.") {
+				pending++
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(workspaceBindingsDesc, prometheus.GaugeValue, float64(bindings[ws.Name]), ws.Name)
+		ch <- prometheus.MustNewConstMetric(pendingPrincipalsDesc, prometheus.GaugeValue, float64(pending), ws.Name)
+	}
+	ch <- prometheus.MustNewConstMetric(workspacesManagedDesc, prometheus.GaugeValue, float64(managed))
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWorkspaceCollector(t *testing.T) {
+	collector := &workspaceCollector{
+		fleetWorkspaces: newFakeCache(
+			&managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Annotations: map[string]string{
+				"gorizond-principal.7.editor": "github_user://7",
+				"gorizond-user.u-abc.admin":   "local://u-abc",
+			}}},
+			&managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-b"}},
+			&managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "fleet-default"}},
+		),
+		globalRoleBindings: newFakeCache(
+			&managementv3.GlobalRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "gorizond-admin-u-abc-workspace-a", Labels: map[string]string{"fleet": "workspace-a"}}},
+			&managementv3.GlobalRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "gorizond-view-g-workspace-a", Labels: map[string]string{"fleet": "workspace-a"}}},
+			&managementv3.GlobalRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "unrelated"}},
+		),
+	}
+
+	expected := `
+# HELP gorizond_pending_principal_resolutions Number of gorizond-principal. annotations per workspace not resolved yet.
+# TYPE gorizond_pending_principal_resolutions gauge
+gorizond_pending_principal_resolutions{workspace="workspace-a"} 1
+gorizond_pending_principal_resolutions{workspace="workspace-b"} 0
+# HELP gorizond_workspace_bindings Number of GlobalRoleBindings per workspace.
+# TYPE gorizond_workspace_bindings gauge
+gorizond_workspace_bindings{workspace="workspace-a"} 2
+gorizond_workspace_bindings{workspace="workspace-b"} 0
+# HELP gorizond_workspaces_managed Number of FleetWorkspaces managed by the controller.
+# TYPE gorizond_workspaces_managed gauge
+gorizond_workspaces_managed 2
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
+	"github.com/rancher/lasso/pkg/log"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultWorkspacePolicyName = "fleet-workspace-controller-policy"
+	workspacePolicyKey         = "personal-workspaces.yaml"
+	// selfWorkspaceSkippedAnnotation tells why a user got no personal workspace.
+	selfWorkspaceSkippedAnnotation = "gorizond-self-workspace-skipped"
+
+	policyProvision = "provision"
+	policySkip      = "skip"
+)
+
+// PersonalWorkspacePolicy decides which users get a personal workspace automatically.
+// Rules are evaluated in order and the first matching rule wins, users matching no rule
+// get the Default action.
+type PersonalWorkspacePolicy struct {
+	Default string       `json:"default,omitempty"`
+	Rules   []PolicyRule `json:"rules,omitempty"`
+}
+
+// PolicyRule matches users by every selector it sets. PrincipalPrefixes and Groups match
+// when any entry matches; Annotations match when every key has the given value, an empty
+// value only requires the key.
+type PolicyRule struct {
+	Name              string                `json:"name"`
+	Action            string                `json:"action"`
+	PrincipalPrefixes []string              `json:"principalPrefixes,omitempty"`
+	LabelSelector     *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	Annotations       map[string]string     `json:"annotations,omitempty"`
+	Groups            []string              `json:"groups,omitempty"`
+
+	selector labels.Selector
+}
+
+// workspacePolicy is the active policy. The user handler reads it from the ConfigMap cache,
+// so users the policy skips never get a workspace from the default policy after a restart.
+var workspacePolicy = newConfigMapSetting(getWorkspacePolicyName, workspacePolicyKey, parseWorkspacePolicy, defaultWorkspacePolicy)
+
+func defaultWorkspacePolicy() *PersonalWorkspacePolicy {
+	return &PersonalWorkspacePolicy{Default: policyProvision}
+}
+
+func parseWorkspacePolicy(data []byte) (*PersonalWorkspacePolicy, error) {
+	policy := &PersonalWorkspacePolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, err
+	}
+	if policy.Default == "" {
+		policy.Default = policyProvision
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (p *PersonalWorkspacePolicy) validate() error {
+	if p.Default != policyProvision && p.Default != policySkip {
+		return fmt.Errorf("default must be %q or %q, got %q", policyProvision, policySkip, p.Default)
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d has no name", i)
+		}
+		if rule.Action != policyProvision && rule.Action != policySkip {
+			return fmt.Errorf("rule %q: action must be %q or %q, got %q", rule.Name, policyProvision, policySkip, rule.Action)
+		}
+		if len(rule.PrincipalPrefixes) == 0 && rule.LabelSelector == nil && len(rule.Annotations) == 0 && len(rule.Groups) == 0 {
+			return fmt.Errorf("rule %q has no selector", rule.Name)
+		}
+		if rule.LabelSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(rule.LabelSelector)
+			if err != nil {
+				return fmt.Errorf("rule %q: %w", rule.Name, err)
+			}
+			rule.selector = selector
+		}
+	}
+	return nil
+}
+
+// decide reports whether the user gets a personal workspace and, if not, why.
+// groups are the group principal IDs of the user.
+func (p *PersonalWorkspacePolicy) decide(user *managementv3.User, groups []string) (bool, string) {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.matches(user, groups) {
+			return rule.Action == policyProvision, fmt.Sprintf("matched rule %q", rule.Name)
+		}
+	}
+	return p.Default == policyProvision, "no rule matched and the default is " + p.Default
+}
+
+func (r *PolicyRule) matches(user *managementv3.User, groups []string) bool {
+	if len(r.PrincipalPrefixes) > 0 && !anyMatch(r.PrincipalPrefixes, user.PrincipalIDs, strings.HasPrefix) {
+		return false
+	}
+	if r.selector != nil && !r.selector.Matches(labels.Set(user.Labels)) {
+		return false
+	}
+	for k, v := range r.Annotations {
+		actual, ok := user.Annotations[k]
+		if !ok || (v != "" && actual != v) {
+			return false
+		}
+	}
+	if len(r.Groups) > 0 && !anyMatch(r.Groups, groups, func(group, want string) bool { return group == want }) {
+		return false
+	}
+	return true
+}
+
+func anyMatch(wants, values []string, match func(value, want string) bool) bool {
+	for _, want := range wants {
+		for _, value := range values {
+			if match(value, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// userGroups returns the group principals Rancher recorded for a user at login.
+// Users who never logged in have no UserAttribute and no groups.
+func userGroups(userAttributes userAttributeGetter, userID string) []string {
+	if userAttributes == nil {
+		return nil
+	}
+	attribute, err := userAttributes.Get(userID)
+	if err != nil {
+		return nil
+	}
+	var groups []string
+	for _, principals := range attribute.GroupPrincipals {
+		for _, principal := range principals.Items {
+			groups = append(groups, principal.Name)
+		}
+	}
+	return groups
+}
+
+func getWorkspacePolicyName() string {
+	if env := os.Getenv("WORKSPACE_POLICY_CONFIGMAP"); env != "" {
+		return env
+	}
+	return defaultWorkspacePolicyName
+}
+
+// InitWorkspacePolicyController loads the personal workspace policy ConfigMap and re-enqueues
+// every User when it changes. Users are also re-evaluated when their groups change.
+func InitWorkspacePolicyController(ctx context.Context, mgmt *management.Factory, configMaps corecontrollers.ConfigMapController) {
+	users := mgmt.Management().V3().User()
+	namespace := ConfigNamespace()
+	name := getWorkspacePolicyName()
+
+	relatedresource.WatchClusterScoped(ctx, "gorizond-user-attribute-watch", func(_, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+		if _, ok := obj.(*managementv3.UserAttribute); !ok {
+			return nil, nil
+		}
+		return []relatedresource.Key{{Name: name}}, nil
+	}, users, mgmt.Management().V3().UserAttribute())
+
+	configMaps.OnChange(ctx, "gorizond-workspace-policy-controller", metrics.Instrument("gorizond-workspace-policy-controller", func(key string, obj *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+		if key != namespace+"/"+name {
+			return obj, nil
+		}
+
+		changed, err := workspacePolicy.sync(obj)
+		if err != nil {
+			log.Errorf("Ignoring invalid personal workspace policy %s: %v", key, err)
+			return obj, nil
+		}
+		if !changed {
+			return obj, nil
+		}
+
+		log.Infof("Personal workspace policy %s changed, reconciling all users", key)
+		all, err := users.Cache().List(labels.Everything())
+		if err != nil {
+			return obj, err
+		}
+		for _, user := range all {
+			users.Enqueue(user.Name)
+		}
+		return obj, nil
+	}))
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"testing"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	rancherv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const testPolicy = `
+default: provision
+rules:
+- name: service-accounts
+  action: skip
+  labelSelector:
+    matchLabels:
+      example.com/service-account: "true"
+- name: opted-out
+  action: skip
+  annotations:
+    example.com/no-workspace: ""
+- name: developers
+  action: provision
+  groups: ["github_org://42"]
+- name: github
+  action: skip
+  principalPrefixes: ["github_user://"]
+`
+
+func TestWorkspacePolicyDecide(t *testing.T) {
+	policy, err := parseWorkspacePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		user      *managementv3.User
+		groups    []string
+		provision bool
+		reason    string
+	}{
+		{
+			name:      "no rule matched",
+			user:      &managementv3.User{PrincipalIDs: []string{"local://u-abc"}},
+			provision: true,
+			reason:    "no rule matched and the default is provision",
+		},
+		{
+			name: "label",
+			user: &managementv3.User{
+				ObjectMeta:   metav1.ObjectMeta{Labels: map[string]string{"example.com/service-account": "true"}},
+				PrincipalIDs: []string{"local://u-abc"},
+			},
+			reason: `matched rule "service-accounts"`,
+		},
+		{
+			name: "annotation presence",
+			user: &managementv3.User{
+				ObjectMeta:   metav1.ObjectMeta{Annotations: map[string]string{"example.com/no-workspace": "yes"}},
+				PrincipalIDs: []string{"local://u-abc"},
+			},
+			reason: `matched rule "opted-out"`,
+		},
+		{
+			name:      "group before principal prefix",
+			user:      &managementv3.User{PrincipalIDs: []string{"github_user://1", "local://u-abc"}},
+			groups:    []string{"github_org://42"},
+			provision: true,
+			reason:    `matched rule "developers"`,
+		},
+		{
+			name:   "principal prefix",
+			user:   &managementv3.User{PrincipalIDs: []string{"github_user://1", "local://u-abc"}},
+			groups: []string{"github_org://7"},
+			reason: `matched rule "github"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provision, reason := policy.decide(tt.user, tt.groups)
+			if provision != tt.provision || reason != tt.reason {
+				t.Fatalf("expected (%v, %q), got (%v, %q)", tt.provision, tt.reason, provision, reason)
+			}
+		})
+	}
+}
+
+func TestParseWorkspacePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "empty", data: ""},
+		{name: "invalid default", data: "default: maybe\n", wantErr: true},
+		{name: "invalid action", data: "rules:\n- name: a\n  action: drop\n  groups: [g]\n", wantErr: true},
+		{name: "rule without name", data: "rules:\n- action: skip\n  groups: [g]\n", wantErr: true},
+		{name: "rule without selector", data: "rules:\n- name: a\n  action: skip\n", wantErr: true},
+		{name: "invalid selector", data: "rules:\n- name: a\n  action: skip\n  labelSelector:\n    matchExpressions:\n    - {key: a, operator: Bogus}\n", wantErr: true},
+		{name: "unknown field", data: "rules:\n- name: a\n  action: skip\n  group: [g]\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWorkspacePolicy([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+		})
+	}
+}
+
+type fakeUserAttributes map[string]*managementv3.UserAttribute
+
+func (f fakeUserAttributes) Get(name string) (*managementv3.UserAttribute, error) {
+	if attribute, ok := f[name]; ok {
+		return attribute, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{}, name)
+}
+
+func TestUserHandlerPolicySkip(t *testing.T) {
+	configMaps := configMapWith(getWorkspacePolicyName(), workspacePolicyKey, "default: skip\nrules:\n- name: developers\n  action: provision\n  groups: [\"github_org://42\"]\n")
+
+	fleetWorkspaces := &fakeFleetWorkspaces{objs: map[string]*managementv3.FleetWorkspace{}}
+	patcher := &fakeUserPatcher{}
+	attributes := fakeUserAttributes{}
+	h := &userHandler{users: patcher, fleetWorkspaces: fleetWorkspaces, userAttributes: attributes, configMaps: configMaps}
+	user := &managementv3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-abc"},
+		PrincipalIDs: []string{"github_user://1", "local://u-abc"},
+		Status:       rancherv3.UserStatus{Conditions: []rancherv3.UserCondition{{Type: "InitialRolesPopulated"}}},
+	}
+
+	if _, err := h.onChange(user.Name, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fleetWorkspaces.objs) != 0 {
+		t.Fatalf("expected no workspace for a skipped user, got %v", fleetWorkspaces.objs)
+	}
+	if string(patcher.lastData) != `{"metadata":{"annotations":{"`+selfWorkspaceSkippedAnnotation+`":"no rule matched and the default is skip"}}}` {
+		t.Fatalf("unexpected patch %s", patcher.lastData)
+	}
+
+	// joining the group provisions the workspace and clears the skip reason
+	attributes["u-abc"] = &managementv3.UserAttribute{GroupPrincipals: map[string]rancherv3.Principals{
+		"github": {Items: []rancherv3.Principal{{ObjectMeta: metav1.ObjectMeta{Name: "github_org://42"}}}},
+	}}
+	user.Annotations = map[string]string{selfWorkspaceSkippedAnnotation: "no rule matched and the default is skip"}
+	if _, err := h.onChange(user.Name, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fleetWorkspaces.objs[selfWorkspaceName("u-abc")]; !ok {
+		t.Fatalf("expected workspace for a group member")
+	}
+	if string(patcher.lastData) != `{"metadata":{"annotations":{"`+userSelfFleetAnnotation+`":"`+selfWorkspaceName("u-abc")+`","`+selfWorkspaceSkippedAnnotation+`":null,"`+selfWorkspaceInitAnnotation+`":"true"}}}` {
+		t.Fatalf("unexpected patch %s", patcher.lastData)
+	}
+}
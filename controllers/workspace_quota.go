@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
+	"github.com/rancher/lasso/pkg/log"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultWorkspaceQuotaName = "fleet-workspace-controller-quota"
+	workspaceQuotaKey         = "quota.yaml"
+)
+
+// WorkspaceQuota limits how many FleetWorkspaces a creator may own, 0 meaning unlimited.
+// A user override wins over group overrides, the most generous matching group wins over
+// the default.
+type WorkspaceQuota struct {
+	Default int            `json:"default,omitempty"`
+	Users   map[string]int `json:"users,omitempty"`
+	Groups  map[string]int `json:"groups,omitempty"`
+}
+
+// workspaceQuota is the active quota. The workspace handler reads it from the ConfigMap cache,
+// workspaces admitted without it would rank ahead of later ones for good.
+var workspaceQuota = newConfigMapSetting(getWorkspaceQuotaName, workspaceQuotaKey, parseWorkspaceQuota, func() *WorkspaceQuota { return &WorkspaceQuota{} })
+
+func parseWorkspaceQuota(data []byte) (*WorkspaceQuota, error) {
+	quota := &WorkspaceQuota{}
+	if err := yaml.UnmarshalStrict(data, quota); err != nil {
+		return nil, err
+	}
+	if quota.Default < 0 {
+		return nil, fmt.Errorf("default must not be negative, got %d", quota.Default)
+	}
+	for user, limit := range quota.Users {
+		if limit < 0 {
+			return nil, fmt.Errorf("user %s: limit must not be negative, got %d", user, limit)
+		}
+	}
+	for group, limit := range quota.Groups {
+		if limit < 0 {
+			return nil, fmt.Errorf("group %s: limit must not be negative, got %d", group, limit)
+		}
+	}
+	return quota, nil
+}
+
+// limit returns the number of workspaces userID may own, 0 when unlimited.
+func (q *WorkspaceQuota) limit(userID string, groups []string) int {
+	if limit, ok := q.Users[userID]; ok {
+		return limit
+	}
+	best, matched := 0, false
+	for _, group := range groups {
+		limit, ok := q.Groups[group]
+		if !ok {
+			continue
+		}
+		if limit == 0 {
+			return 0
+		}
+		if !matched || limit > best {
+			best, matched = limit, true
+		}
+	}
+	if matched {
+		return best
+	}
+	return q.Default
+}
+
+// workspacesAhead counts the workspaces of the creator of obj admitted before it: those
+// already initialized and, among new ones, those created earlier. Workspaces that existed
+// before a limit was lowered therefore stay, only new ones are rejected.
+func workspacesAhead(obj *managementv3.FleetWorkspace, workspaces []*managementv3.FleetWorkspace) int {
+	creator := obj.Annotations["field.cattle.io/creatorId"]
+	ahead := 0
+	for _, ws := range workspaces {
+		if ws.Name == obj.Name || ws.DeletionTimestamp != nil || ws.Annotations["field.cattle.io/creatorId"] != creator {
+			continue
+		}
+		if ws.Annotations["workspace-roles-init"] == "true" {
+			ahead++
+			continue
+		}
+		if ws.CreationTimestamp.Before(&obj.CreationTimestamp) ||
+			(ws.CreationTimestamp.Equal(&obj.CreationTimestamp) && ws.Name < obj.Name) {
+			ahead++
+		}
+	}
+	return ahead
+}
+
+// ensureWorkspaceQuota deletes a new workspace whose creator already owns as many workspaces
+// as the quota allows, like ensureWorkspacePrefix does for a wrong name, and reports whether
+// it did. The personal workspace is never rejected, the user controller would recreate it.
+func (h *fleetWorkspaceHandler) ensureWorkspaceQuota(obj *managementv3.FleetWorkspace) (bool, error) {
+	creator := obj.Annotations["field.cattle.io/creatorId"]
+	if creator == "" || obj.Annotations["workspace-roles-init"] == "true" || obj.Name == selfWorkspaceName(creator) {
+		return false, nil
+	}
+	quota, err := workspaceQuota.read(h.configMaps)
+	if err != nil {
+		return false, err
+	}
+	limit := quota.limit(creator, userGroups(h.userAttributes, creator))
+	if limit == 0 {
+		return false, nil
+	}
+
+	workspaces, err := h.fleetWorkspaces.Cache().List(labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	ahead := workspacesAhead(obj, workspaces)
+	if ahead < limit {
+		return false, nil
+	}
+
+	message := fmt.Sprintf("user %s already owns %d fleet workspaces, the limit is %d", creator, ahead, limit)
+	log.Infof("Deleting fleet workspace %q over quota: %s", obj.Name, message)
+	if h.recorder != nil {
+		h.recorder.Event(obj, corev1.EventTypeWarning, "QuotaExceeded", message)
+	}
+	if err := h.fleetWorkspaces.Delete(obj.Name, nil); err != nil && !errors.IsNotFound(err) {
+		return true, err
+	}
+	return true, nil
+}
+
+func getWorkspaceQuotaName() string {
+	if env := os.Getenv("WORKSPACE_QUOTA_CONFIGMAP"); env != "" {
+		return env
+	}
+	return defaultWorkspaceQuotaName
+}
+
+// InitWorkspaceQuotaController loads the workspace quota ConfigMap. The quota applies to
+// workspaces created afterwards, so nothing is re-enqueued when it changes.
+func InitWorkspaceQuotaController(ctx context.Context, configMaps corecontrollers.ConfigMapController) {
+	namespace := ConfigNamespace()
+	name := getWorkspaceQuotaName()
+
+	configMaps.OnChange(ctx, "gorizond-workspace-quota-controller", metrics.Instrument("gorizond-workspace-quota-controller", func(key string, obj *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+		if key != namespace+"/"+name {
+			return obj, nil
+		}
+
+		changed, err := workspaceQuota.sync(obj)
+		if err != nil {
+			log.Errorf("Ignoring invalid workspace quota %s: %v", key, err)
+			return obj, nil
+		}
+		if changed {
+			log.Infof("Workspace quota %s changed", key)
+		}
+		return obj, nil
+	}))
+}
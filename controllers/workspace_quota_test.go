@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWorkspaceQuotaLimit(t *testing.T) {
+	quota, err := parseWorkspaceQuota([]byte(`
+default: 3
+users:
+  u-vip: 0
+  u-limited: 1
+groups:
+  github_org://1: 5
+  github_org://2: 10
+  github_org://admins: 0
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		groups []string
+		want   int
+	}{
+		{name: "default", user: "u-abc", want: 3},
+		{name: "user override", user: "u-limited", groups: []string{"github_org://2"}, want: 1},
+		{name: "user unlimited", user: "u-vip", want: 0},
+		{name: "most generous group", user: "u-abc", groups: []string{"github_org://1", "github_org://2"}, want: 10},
+		{name: "unlimited group", user: "u-abc", groups: []string{"github_org://1", "github_org://admins"}, want: 0},
+		{name: "unknown group", user: "u-abc", groups: []string{"github_org://3"}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quota.limit(tt.user, tt.groups); got != tt.want {
+				t.Fatalf("expected limit %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := parseWorkspaceQuota([]byte("default: -1\n")); err == nil {
+		t.Fatalf("expected negative default to be rejected")
+	}
+	if _, err := parseWorkspaceQuota([]byte("users:\n  u-abc: -2\n")); err == nil {
+		t.Fatalf("expected negative override to be rejected")
+	}
+	if _, err := parseWorkspaceQuota([]byte("limit: 3\n")); err == nil {
+		t.Fatalf("expected unknown field to be rejected")
+	}
+
+	// the handler reads the configured quota, not the unlimited placeholder
+	configured, err := workspaceQuota.read(configMapWith(getWorkspaceQuotaName(), workspaceQuotaKey, "default: 2\n"))
+	if err != nil || configured.limit("u-abc", nil) != 2 {
+		t.Fatalf("read() = %+v, %v, want the configured default", configured, err)
+	}
+}
+
+func TestWorkspacesAhead(t *testing.T) {
+	now := time.Now()
+	deleting := metav1.NewTime(now)
+	ws := func(name, creator string, age time.Duration, initialized bool) *managementv3.FleetWorkspace {
+		obj := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			Annotations:       map[string]string{"field.cattle.io/creatorId": creator},
+		}}
+		if initialized {
+			obj.Annotations["workspace-roles-init"] = "true"
+		}
+		return obj
+	}
+
+	candidate := ws("workspace-c", "u-abc", time.Minute, false)
+	gone := ws("workspace-gone", "u-abc", time.Hour, true)
+	gone.DeletionTimestamp = &deleting
+	workspaces := []*managementv3.FleetWorkspace{
+		candidate,
+		ws("workspace-a", "u-abc", time.Hour, true),
+		// initialized workspaces count even when newer, they were admitted already
+		ws("workspace-z", "u-abc", 0, true),
+		ws("workspace-b", "u-abc", 2*time.Minute, false),
+		ws("workspace-d", "u-abc", 0, false),
+		ws("workspace-other", "u-other", time.Hour, true),
+		gone,
+	}
+
+	if got := workspacesAhead(candidate, workspaces); got != 3 {
+		t.Fatalf("expected 3 workspaces ahead, got %d", got)
+	}
+
+	// workspaces created in the same second are ordered by name
+	twin := ws("workspace-b2", "u-abc", 2*time.Minute, false)
+	twin.CreationTimestamp = workspaces[3].CreationTimestamp
+	if got := workspacesAhead(twin, workspaces); got != 3 {
+		t.Fatalf("expected 3 workspaces ahead of the twin, got %d", got)
+	}
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/genericcondition"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// workspaceStatusAnnotation carries the reconcile conditions, FleetWorkspace has no status fields of its own.
+	workspaceStatusAnnotation = "gorizond-status"
+
+	conditionRolesReady                = "RolesReady"
+	conditionMembersBound              = "MembersBound"
+	conditionPrincipalResolutionFailed = "PrincipalResolutionFailed"
+	conditionUsersProvisioned          = "UsersProvisioned"
+	conditionTemplateApplied           = "TemplateApplied"
+	conditionLastAdminRemoved          = "LastAdminRemoved"
+	conditionOwnershipTransferred      = "OwnershipTransferred"
+	conditionCreatorMissing            = "CreatorMissing"
+)
+
+// workspaceStatus is the JSON document stored in the gorizond-status annotation.
+type workspaceStatus struct {
+	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
+}
+
+// problemConditions are the conditions whose True status means something went wrong.
+var problemConditions = map[string]bool{
+	conditionPrincipalResolutionFailed: true,
+	conditionLastAdminRemoved:          true,
+	conditionCreatorMissing:            true,
+}
+
+func readWorkspaceStatus(obj *managementv3.FleetWorkspace) workspaceStatus {
+	var status workspaceStatus
+	if data := obj.Annotations[workspaceStatusAnnotation]; data != "" {
+		_ = json.Unmarshal([]byte(data), &status)
+	}
+	return status
+}
+
+// set records a condition, replacing an earlier one of the same type.
+func (s *workspaceStatus) set(condType string, ok bool, reason, message string) {
+	status := corev1.ConditionFalse
+	if ok {
+		status = corev1.ConditionTrue
+	}
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == condType {
+			s.Conditions[i].Status = status
+			s.Conditions[i].Reason = reason
+			s.Conditions[i].Message = message
+			return
+		}
+	}
+	s.Conditions = append(s.Conditions, genericcondition.GenericCondition{
+		Type:    condType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+func (s *workspaceStatus) get(condType string) *genericcondition.GenericCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == condType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// applyWorkspaceStatus writes status into the annotations of obj, which must be a copy
+// safe to mutate, and returns the conditions that changed since the last reconcile.
+// Conditions that did not change keep their transition time so the annotation stays stable.
+func applyWorkspaceStatus(obj *managementv3.FleetWorkspace, status workspaceStatus) []genericcondition.GenericCondition {
+	previous := readWorkspaceStatus(obj)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	var changed []genericcondition.GenericCondition
+	for i := range status.Conditions {
+		cond := &status.Conditions[i]
+		old := previous.get(cond.Type)
+		if old != nil && old.Status == cond.Status && old.Reason == cond.Reason && old.Message == cond.Message {
+			cond.LastTransitionTime = old.LastTransitionTime
+			cond.LastUpdateTime = old.LastUpdateTime
+			continue
+		}
+		cond.LastUpdateTime = now
+		if old == nil || old.Status != cond.Status {
+			cond.LastTransitionTime = now
+		} else {
+			cond.LastTransitionTime = old.LastTransitionTime
+		}
+		changed = append(changed, *cond)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	sort.Slice(status.Conditions, func(i, j int) bool {
+		return status.Conditions[i].Type < status.Conditions[j].Type
+	})
+	data, err := json.Marshal(status)
+	if err != nil {
+		return nil
+	}
+	if obj.Annotations == nil {
+		obj.Annotations = map[string]string{}
+	}
+	obj.Annotations[workspaceStatusAnnotation] = string(data)
+	return changed
+}
+
+// recordConditionEvents emits an Event on the workspace for every changed condition.
+func recordConditionEvents(recorder record.EventRecorder, obj *managementv3.FleetWorkspace, changed []genericcondition.GenericCondition) {
+	if recorder == nil {
+		return
+	}
+	for _, cond := range changed {
+		eventType := corev1.EventTypeNormal
+		if (cond.Status == corev1.ConditionTrue) == problemConditions[cond.Type] {
+			eventType = corev1.EventTypeWarning
+		}
+		message := cond.Type + " is " + string(cond.Status)
+		if cond.Message != "" {
+			message += ": " + cond.Message
+		}
+		recorder.Event(obj, eventType, cond.Reason, message)
+	}
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestApplyWorkspaceStatus(t *testing.T) {
+	ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-demo"}}
+
+	status := readWorkspaceStatus(ws)
+	status.set(conditionRolesReady, true, "Synced", "")
+	status.set(conditionPrincipalResolutionFailed, true, "ResolveFailed", "github_user://1: not found")
+	changed := applyWorkspaceStatus(ws, status)
+	if len(changed) != 2 {
+		t.Fatalf("expected 2 changed conditions, got %d", len(changed))
+	}
+	first := ws.Annotations[workspaceStatusAnnotation]
+	if first == "" {
+		t.Fatalf("expected status annotation to be written")
+	}
+
+	// same result again must leave the annotation untouched
+	status = readWorkspaceStatus(ws)
+	status.set(conditionRolesReady, true, "Synced", "")
+	status.set(conditionPrincipalResolutionFailed, true, "ResolveFailed", "github_user://1: not found")
+	if changed := applyWorkspaceStatus(ws, status); len(changed) != 0 {
+		t.Fatalf("expected no changes, got %v", changed)
+	}
+	if ws.Annotations[workspaceStatusAnnotation] != first {
+		t.Fatalf("annotation changed without condition change")
+	}
+
+	status = readWorkspaceStatus(ws)
+	status.set(conditionPrincipalResolutionFailed, false, "Resolved", "")
+	changed = applyWorkspaceStatus(ws, status)
+	if len(changed) != 1 || changed[0].Type != conditionPrincipalResolutionFailed {
+		t.Fatalf("expected only %s to change, got %v", conditionPrincipalResolutionFailed, changed)
+	}
+	status = readWorkspaceStatus(ws)
+	if got := status.get(conditionRolesReady); got == nil || got.Status != corev1.ConditionTrue {
+		t.Fatalf("expected %s to be kept, got %v", conditionRolesReady, got)
+	}
+}
+
+func TestRecordConditionEvents(t *testing.T) {
+	ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-demo"}}
+	recorder := record.NewFakeRecorder(10)
+
+	var status workspaceStatus
+	status.set(conditionRolesReady, false, "SyncFailed", "boom")
+	status.set(conditionMembersBound, true, "Bound", "")
+	status.set(conditionPrincipalResolutionFailed, true, "ResolveFailed", "github_user://1: not found")
+	recordConditionEvents(recorder, ws, status.Conditions)
+
+	want := []string{
+		"Warning SyncFailed RolesReady is False: boom",
+		"Normal Bound MembersBound is True",
+		"Warning ResolveFailed PrincipalResolutionFailed is True: github_user://1: not found",
+	}
+	for _, w := range want {
+		got := <-recorder.Events
+		if !strings.HasPrefix(got, w) {
+			t.Fatalf("expected event %q, got %q", w, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	fleetv1 "github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	"github.com/rancher/lasso/pkg/log"
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// workspaceTemplateAnnotation names the WorkspaceTemplate of a FleetWorkspace, overriding
+	// template workspace selectors.
+	workspaceTemplateAnnotation = "gorizond-template"
+	// templateAppliedAnnotation records the template seeded into the workspace, empty when
+	// none matched or the workspace predates templates. Its presence stops the template from
+	// being applied again, unless the template annotation names another one.
+	templateAppliedAnnotation = "gorizond-template-applied"
+	// templateReapplyAnnotation requests the template to be applied again, it is removed
+	// once done. Resources an earlier apply created but the template no longer lists are removed.
+	templateReapplyAnnotation = "gorizond-template-reapply"
+)
+
+var templateGVKs = []schema.GroupVersionKind{
+	fleetv1.SchemeGroupVersion.WithKind("ClusterGroup"),
+	fleetv1.SchemeGroupVersion.WithKind("GitRepoRestriction"),
+	fleetv1.SchemeGroupVersion.WithKind("GitRepo"),
+}
+
+// templateApplier applies objs into a workspace namespace as one set, pruning objects of
+// earlier applies that are not part of objs anymore.
+type templateApplier func(workspace string, objs ...runtime.Object) error
+
+type workspaceTemplateGetter interface {
+	Get(name string) (*workspacev1.WorkspaceTemplate, error)
+	List(selector labels.Selector) ([]*workspacev1.WorkspaceTemplate, error)
+}
+
+func newTemplateApplier(apply apply.Apply) templateApplier {
+	return func(workspace string, objs ...runtime.Object) error {
+		return apply.
+			WithSetID("gorizond-template-" + workspace).
+			WithDynamicLookup().
+			WithDefaultNamespace(workspace).
+			WithListerNamespace(workspace).
+			WithGVK(templateGVKs...).
+			ApplyObjects(objs...)
+	}
+}
+
+// selectWorkspaceTemplate returns the template named by the workspace annotation, or else the
+// first template by name whose selector matches the workspace labels. It returns nil when no
+// template applies.
+func selectWorkspaceTemplate(templates workspaceTemplateGetter, obj *managementv3.FleetWorkspace) (*workspacev1.WorkspaceTemplate, error) {
+	if name := obj.Annotations[workspaceTemplateAnnotation]; name != "" {
+		template, err := templates.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("workspace template %s: %w", name, err)
+		}
+		return template, nil
+	}
+
+	all, err := templates.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	for _, template := range all {
+		if template.Spec.WorkspaceSelector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(template.Spec.WorkspaceSelector)
+		if err != nil {
+			log.Errorf("Ignoring workspace template %s with invalid selector: %v", template.Name, err)
+			continue
+		}
+		if selector.Matches(labels.Set(obj.Labels)) {
+			return template, nil
+		}
+	}
+	return nil, nil
+}
+
+// templateObjects renders the Fleet resources of template into the workspace namespace.
+func templateObjects(template *workspacev1.WorkspaceTemplate, workspace string) []runtime.Object {
+	meta := func(name string, l map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: workspace, Labels: l}
+	}
+
+	var objs []runtime.Object
+	for _, group := range template.Spec.ClusterGroups {
+		objs = append(objs, &fleetv1.ClusterGroup{
+			TypeMeta:   metav1.TypeMeta{APIVersion: fleetv1.SchemeGroupVersion.String(), Kind: "ClusterGroup"},
+			ObjectMeta: meta(group.Name, group.Labels),
+			Spec:       *group.Spec.DeepCopy(),
+		})
+	}
+	for _, restriction := range template.Spec.GitRepoRestrictions {
+		objs = append(objs, &fleetv1.GitRepoRestriction{
+			TypeMeta:                 metav1.TypeMeta{APIVersion: fleetv1.SchemeGroupVersion.String(), Kind: "GitRepoRestriction"},
+			ObjectMeta:               meta(restriction.Name, restriction.Labels),
+			DefaultServiceAccount:    restriction.DefaultServiceAccount,
+			AllowedServiceAccounts:   restriction.AllowedServiceAccounts,
+			AllowedRepoPatterns:      restriction.AllowedRepoPatterns,
+			DefaultClientSecretName:  restriction.DefaultClientSecretName,
+			AllowedClientSecretNames: restriction.AllowedClientSecretNames,
+			AllowedTargetNamespaces:  restriction.AllowedTargetNamespaces,
+		})
+	}
+	for _, repo := range template.Spec.GitRepos {
+		objs = append(objs, &fleetv1.GitRepo{
+			TypeMeta:   metav1.TypeMeta{APIVersion: fleetv1.SchemeGroupVersion.String(), Kind: "GitRepo"},
+			ObjectMeta: meta(repo.Name, repo.Labels),
+			Spec:       *repo.Spec.DeepCopy(),
+		})
+	}
+	return objs
+}
+
+// seedTemplate applies the workspace template when the workspace is first initialized, when
+// the template annotation names a template not applied yet and whenever the reapply annotation
+// is set. Workspaces initialized before templates existed are only marked as seeded, so an
+// upgrade does not apply templates to them. It reports whether annotations of obj changed.
+func (h *fleetWorkspaceHandler) seedTemplate(obj *managementv3.FleetWorkspace, status *workspaceStatus) (bool, error) {
+	if h.templates == nil {
+		return false, nil
+	}
+	applied, seeded := obj.Annotations[templateAppliedAnnotation]
+	_, reapply := obj.Annotations[templateReapplyAnnotation]
+	named := obj.Annotations[workspaceTemplateAnnotation]
+	switch {
+	case reapply:
+	case seeded && (named == "" || named == applied):
+		return false, nil
+	case !seeded && named == "" && obj.Annotations["workspace-roles-init"] == "true":
+		obj.Annotations[templateAppliedAnnotation] = ""
+		return true, nil
+	}
+
+	template, err := selectWorkspaceTemplate(h.templates, obj)
+	if err != nil {
+		status.set(conditionTemplateApplied, false, "TemplateNotFound", err.Error())
+		return false, err
+	}
+
+	// a reapply without a template still runs, so resources of an earlier template are pruned
+	var objs []runtime.Object
+	if template != nil {
+		objs = templateObjects(template, obj.Name)
+	}
+	if template != nil || reapply {
+		if err := h.applyTemplate(obj.Name, objs...); err != nil {
+			status.set(conditionTemplateApplied, false, "ApplyFailed", err.Error())
+			return false, fmt.Errorf("failed to apply workspace template: %w", err)
+		}
+	}
+
+	name := ""
+	if template != nil {
+		name = template.Name
+		status.set(conditionTemplateApplied, true, "Applied", name)
+	} else if reapply {
+		status.set(conditionTemplateApplied, true, "NoTemplate", "")
+	}
+	obj.Annotations[templateAppliedAnnotation] = name
+	delete(obj.Annotations, templateReapplyAnnotation)
+	return true, nil
+}
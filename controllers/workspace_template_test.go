@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	fleetv1 "github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeWorkspaceTemplates map[string]*workspacev1.WorkspaceTemplate
+
+func (f fakeWorkspaceTemplates) Get(name string) (*workspacev1.WorkspaceTemplate, error) {
+	if template, ok := f[name]; ok {
+		return template, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{}, name)
+}
+
+func (f fakeWorkspaceTemplates) List(selector labels.Selector) ([]*workspacev1.WorkspaceTemplate, error) {
+	var result []*workspacev1.WorkspaceTemplate
+	for _, template := range f {
+		result = append(result, template)
+	}
+	return result, nil
+}
+
+func testTemplates() fakeWorkspaceTemplates {
+	return fakeWorkspaceTemplates{
+		"default": {
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Spec: workspacev1.WorkspaceTemplateSpec{
+				WorkspaceSelector: &metav1.LabelSelector{},
+				ClusterGroups:     []workspacev1.TemplateClusterGroup{{Name: "all"}},
+			},
+		},
+		"a-team": {
+			ObjectMeta: metav1.ObjectMeta{Name: "a-team"},
+			Spec: workspacev1.WorkspaceTemplateSpec{
+				WorkspaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				GitRepoRestrictions: []workspacev1.TemplateGitRepoRestriction{
+					{Name: "restriction", AllowedRepoPatterns: []string{"^https://github.com/example/.*"}},
+				},
+				GitRepos: []workspacev1.TemplateGitRepo{
+					{Name: "onboarding", Spec: fleetv1.GitRepoSpec{Repo: "https://github.com/example/onboarding"}},
+				},
+			},
+		},
+		"manual": {
+			ObjectMeta: metav1.ObjectMeta{Name: "manual"},
+		},
+	}
+}
+
+func TestSelectWorkspaceTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        string
+		wantErr     bool
+	}{
+		{name: "selector", labels: map[string]string{"team": "a"}, want: "a-team"},
+		{name: "empty selector matches all", want: "default"},
+		{name: "annotation", labels: map[string]string{"team": "a"}, annotations: map[string]string{workspaceTemplateAnnotation: "manual"}, want: "manual"},
+		{name: "missing template", annotations: map[string]string{workspaceTemplateAnnotation: "missing"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Labels: tt.labels, Annotations: tt.annotations}}
+			template, err := selectWorkspaceTemplate(testTemplates(), ws)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if err == nil && template.Name != tt.want {
+				t.Fatalf("expected template %s, got %s", tt.want, template.Name)
+			}
+		})
+	}
+
+	ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a"}}
+	templates := testTemplates()
+	delete(templates, "default")
+	if template, err := selectWorkspaceTemplate(templates, ws); err != nil || template != nil {
+		t.Fatalf("expected no template, got %v, %v", template, err)
+	}
+}
+
+func TestTemplateObjects(t *testing.T) {
+	objs := templateObjects(testTemplates()["a-team"], "workspace-a")
+	if len(objs) != 2 {
+		t.Fatalf("expected 2 objects, got %d", len(objs))
+	}
+	restriction, ok := objs[0].(*fleetv1.GitRepoRestriction)
+	if !ok || restriction.Namespace != "workspace-a" || restriction.Kind != "GitRepoRestriction" || len(restriction.AllowedRepoPatterns) != 1 {
+		t.Fatalf("unexpected restriction %#v", objs[0])
+	}
+	repo, ok := objs[1].(*fleetv1.GitRepo)
+	if !ok || repo.Namespace != "workspace-a" || repo.APIVersion != "fleet.cattle.io/v1alpha1" || repo.Spec.Repo != "https://github.com/example/onboarding" {
+		t.Fatalf("unexpected gitrepo %#v", objs[1])
+	}
+}
+
+func TestSeedTemplate(t *testing.T) {
+	var applies [][]runtime.Object
+	var applyErr error
+	h := &fleetWorkspaceHandler{
+		templates: testTemplates(),
+		applyTemplate: func(workspace string, objs ...runtime.Object) error {
+			applies = append(applies, objs)
+			return applyErr
+		},
+	}
+	ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "workspace-a",
+		Labels:      map[string]string{"team": "a"},
+		Annotations: map[string]string{},
+	}}
+	status := workspaceStatus{}
+
+	// the namespace may not exist yet right after creation
+	applyErr = fmt.Errorf("namespace not found")
+	if _, err := h.seedTemplate(ws, &status); err == nil {
+		t.Fatalf("expected apply error")
+	}
+	if _, ok := ws.Annotations[templateAppliedAnnotation]; ok {
+		t.Fatalf("failed apply must not be recorded")
+	}
+	if cond := status.get(conditionTemplateApplied); cond == nil || cond.Reason != "ApplyFailed" {
+		t.Fatalf("expected ApplyFailed condition, got %+v", cond)
+	}
+
+	applyErr = nil
+	changed, err := h.seedTemplate(ws, &status)
+	if err != nil || !changed {
+		t.Fatalf("expected template to be applied, got %v, %v", changed, err)
+	}
+	if ws.Annotations[templateAppliedAnnotation] != "a-team" || len(applies[1]) != 2 {
+		t.Fatalf("unexpected apply %v / %v", ws.Annotations, applies)
+	}
+
+	// applied once, template changes do not leak into existing workspaces
+	if changed, _ := h.seedTemplate(ws, &status); changed || len(applies) != 2 {
+		t.Fatalf("expected no second apply")
+	}
+
+	// a reapply request without a matching template prunes the earlier resources
+	ws.Labels = nil
+	h.templates = fakeWorkspaceTemplates{}
+	ws.Annotations[templateReapplyAnnotation] = "true"
+	if changed, err := h.seedTemplate(ws, &status); err != nil || !changed {
+		t.Fatalf("expected reapply, got %v, %v", changed, err)
+	}
+	if len(applies) != 3 || len(applies[2]) != 0 {
+		t.Fatalf("expected an empty apply, got %v", applies)
+	}
+	if _, ok := ws.Annotations[templateReapplyAnnotation]; ok || ws.Annotations[templateAppliedAnnotation] != "" {
+		t.Fatalf("unexpected annotations %v", ws.Annotations)
+	}
+}
+
+func TestSeedTemplateExistingWorkspace(t *testing.T) {
+	var applies []string
+	h := &fleetWorkspaceHandler{
+		templates: testTemplates(),
+		applyTemplate: func(workspace string, objs ...runtime.Object) error {
+			applies = append(applies, workspace)
+			return nil
+		},
+	}
+	// initialized before templates existed, the selector matches but nothing is applied
+	ws := &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "workspace-a",
+		Labels:      map[string]string{"team": "a"},
+		Annotations: map[string]string{"workspace-roles-init": "true"},
+	}}
+	status := workspaceStatus{}
+
+	changed, err := h.seedTemplate(ws, &status)
+	if err != nil || !changed {
+		t.Fatalf("expected the workspace to be marked, got %v, %v", changed, err)
+	}
+	if applied, ok := ws.Annotations[templateAppliedAnnotation]; !ok || applied != "" || len(applies) != 0 {
+		t.Fatalf("unexpected apply %v / %v", ws.Annotations, applies)
+	}
+	if changed, _ := h.seedTemplate(ws, &status); changed || len(applies) != 0 {
+		t.Fatalf("expected a marked workspace to stay untouched")
+	}
+
+	// naming a template applies it once
+	ws.Annotations[workspaceTemplateAnnotation] = "a-team"
+	if changed, err := h.seedTemplate(ws, &status); err != nil || !changed {
+		t.Fatalf("expected the named template to be applied, got %v, %v", changed, err)
+	}
+	if ws.Annotations[templateAppliedAnnotation] != "a-team" || len(applies) != 1 {
+		t.Fatalf("unexpected apply %v / %v", ws.Annotations, applies)
+	}
+	if changed, _ := h.seedTemplate(ws, &status); changed || len(applies) != 1 {
+		t.Fatalf("expected no second apply")
+	}
+}
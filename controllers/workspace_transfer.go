@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"fmt"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/lasso/pkg/log"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// transferToAnnotation requests the workspace to be handed over to another user, it is
+	// removed once the transfer is done.
+	transferToAnnotation = "gorizond-transfer-to"
+	// transferredFromAnnotation records the owner the workspace was last transferred from.
+	transferredFromAnnotation = "gorizond-transferred-from"
+)
+
+// transferOwnership hands the workspace to the user named by the transfer annotation: the
+// creator is rewritten, the admin annotation of the previous owner moves to the new one, so
+// the stale binding cleanup removes the old admin binding, and the gorizond-self-fleet
+// annotations of both users are updated. It reports whether annotations of obj changed.
+func (h *fleetWorkspaceHandler) transferOwnership(obj *managementv3.FleetWorkspace, status *workspaceStatus) (bool, error) {
+	target, ok := obj.Annotations[transferToAnnotation]
+	if !ok {
+		return false, nil
+	}
+	previous := obj.Annotations["field.cattle.io/creatorId"]
+	if target == "" || target == previous {
+		delete(obj.Annotations, transferToAnnotation)
+		return true, nil
+	}
+
+	targetUser, err := h.users.Get(target)
+	if err != nil {
+		status.set(conditionOwnershipTransferred, false, "UserNotFound", fmt.Sprintf("cannot transfer to %s: %v", target, err))
+		return false, fmt.Errorf("failed to transfer workspace %s to %s: %w", obj.Name, target, err)
+	}
+	key, value, err := creatorAdminAnnotation(h.users, target)
+	if err != nil {
+		return false, err
+	}
+
+	// point the new owner at the workspace first, a failure leaves the transfer pending
+	if targetUser.Annotations[userSelfFleetAnnotation] == "" {
+		if err := patchUserAnnotations(h.userClient, target, map[string]interface{}{
+			selfWorkspaceInitAnnotation: "true",
+			userSelfFleetAnnotation:     obj.Name,
+		}); err != nil {
+			return false, err
+		}
+	}
+	if previous != "" {
+		if previousUser, err := h.users.Get(previous); err == nil && previousUser.Annotations[userSelfFleetAnnotation] == obj.Name {
+			// the user controller adopts another workspace of the previous owner, if any
+			if err := patchUserAnnotations(h.userClient, previous, map[string]interface{}{
+				userSelfFleetAnnotation: nil,
+			}); err != nil && !errors.IsNotFound(err) {
+				return false, err
+			}
+		} else if err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+		delete(obj.Annotations, "gorizond-user."+previous+"."+adminRole)
+	}
+
+	obj.Annotations[key] = value
+	obj.Annotations["field.cattle.io/creatorId"] = target
+	obj.Annotations[transferredFromAnnotation] = previous
+	delete(obj.Annotations, transferToAnnotation)
+	status.set(conditionOwnershipTransferred, true, "Transferred", fmt.Sprintf("transferred from %s to %s", previous, target))
+	log.Infof("Transferred fleet workspace %s from %s to %s", obj.Name, previous, target)
+	return true, nil
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// recordingUserPatcher keeps the annotation updates patched into each user.
+type recordingUserPatcher map[string]map[string]interface{}
+
+func (f recordingUserPatcher) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*managementv3.User, error) {
+	var patch struct {
+		Metadata struct {
+			Annotations map[string]interface{} `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	f[name] = patch.Metadata.Annotations
+	return &managementv3.User{}, nil
+}
+
+func TestTransferOwnership(t *testing.T) {
+	users := newFakeCache(
+		&managementv3.User{
+			ObjectMeta:   metav1.ObjectMeta{Name: "u-old", Annotations: map[string]string{userSelfFleetAnnotation: "workspace-a"}},
+			PrincipalIDs: []string{"local://u-old"},
+		},
+		&managementv3.User{
+			ObjectMeta:   metav1.ObjectMeta{Name: "u-new"},
+			PrincipalIDs: []string{"local://u-new", "github_user://7"},
+		},
+		&managementv3.User{
+			ObjectMeta:   metav1.ObjectMeta{Name: "u-busy", Annotations: map[string]string{userSelfFleetAnnotation: "workspace-busy"}},
+			PrincipalIDs: []string{"local://u-busy"},
+		},
+	)
+	workspace := func(target string) *managementv3.FleetWorkspace {
+		return &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-a", Annotations: map[string]string{
+			"field.cattle.io/creatorId":   "u-old",
+			"gorizond-user.u-old.admin":   "local://u-old",
+			"gorizond-user.u-member.view": "local://u-member",
+			transferToAnnotation:          target,
+		}}}
+	}
+
+	t.Run("transfer", func(t *testing.T) {
+		patches := recordingUserPatcher{}
+		h := &fleetWorkspaceHandler{users: users, userClient: patches}
+		obj := workspace("u-new")
+		status := workspaceStatus{}
+
+		changed, err := h.transferOwnership(obj, &status)
+		if err != nil || !changed {
+			t.Fatalf("transferOwnership() = %v, %v", changed, err)
+		}
+		want := map[string]string{
+			"field.cattle.io/creatorId":   "u-new",
+			"gorizond-user.u-new.admin":   "github_user://7",
+			"gorizond-user.u-member.view": "local://u-member",
+			transferredFromAnnotation:     "u-old",
+		}
+		if len(obj.Annotations) != len(want) {
+			t.Fatalf("annotations = %v, want %v", obj.Annotations, want)
+		}
+		for k, v := range want {
+			if obj.Annotations[k] != v {
+				t.Errorf("annotation %s = %q, want %q", k, obj.Annotations[k], v)
+			}
+		}
+		if patches["u-new"][userSelfFleetAnnotation] != "workspace-a" {
+			t.Errorf("new owner patch = %v, want the workspace as gorizond-self-fleet", patches["u-new"])
+		}
+		if v, ok := patches["u-old"][userSelfFleetAnnotation]; !ok || v != nil {
+			t.Errorf("previous owner patch = %v, want gorizond-self-fleet removed", patches["u-old"])
+		}
+		if cond := status.get(conditionOwnershipTransferred); cond == nil || cond.Status != corev1.ConditionTrue {
+			t.Errorf("condition = %+v, want True", cond)
+		}
+	})
+
+	t.Run("keeps the new owner's personal workspace", func(t *testing.T) {
+		patches := recordingUserPatcher{}
+		h := &fleetWorkspaceHandler{users: users, userClient: patches}
+		if _, err := h.transferOwnership(workspace("u-busy"), &workspaceStatus{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := patches["u-busy"]; ok {
+			t.Errorf("user with a personal workspace must not be patched, got %v", patches["u-busy"])
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		patches := recordingUserPatcher{}
+		h := &fleetWorkspaceHandler{users: users, userClient: patches}
+		obj := workspace("u-missing")
+		status := workspaceStatus{}
+		if _, err := h.transferOwnership(obj, &status); err == nil {
+			t.Fatalf("expected an error for an unknown user")
+		}
+		if obj.Annotations["field.cattle.io/creatorId"] != "u-old" || obj.Annotations[transferToAnnotation] != "u-missing" {
+			t.Errorf("failed transfer must leave the workspace untouched, got %v", obj.Annotations)
+		}
+		if cond := status.get(conditionOwnershipTransferred); cond == nil || cond.Reason != "UserNotFound" {
+			t.Errorf("condition = %+v, want UserNotFound", cond)
+		}
+		if len(patches) != 0 {
+			t.Errorf("unexpected user patches %v", patches)
+		}
+	})
+
+	t.Run("same owner", func(t *testing.T) {
+		h := &fleetWorkspaceHandler{users: users, userClient: recordingUserPatcher{}}
+		obj := workspace("u-old")
+		changed, err := h.transferOwnership(obj, &workspaceStatus{})
+		if err != nil || !changed {
+			t.Fatalf("transferOwnership() = %v, %v", changed, err)
+		}
+		if _, ok := obj.Annotations[transferToAnnotation]; ok || obj.Annotations["gorizond-user.u-old.admin"] == "" {
+			t.Errorf("expected only the request to be dropped, got %v", obj.Annotations)
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// membershipPrefixes are the annotation prefixes granting a role in a workspace,
+// each followed by <id>.<role>.
+var membershipPrefixes = []string{"gorizond-user.", "gorizond-group.", "gorizond-principal."}
+
+// membershipPrefix returns the membership prefix of an annotation key, empty for other keys.
+func membershipPrefix(key string) string {
+	for _, prefix := range membershipPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// parseMembershipKey splits a <prefix><id>.<role> annotation key.
+func parseMembershipKey(prefix, key string) (string, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(key, prefix), ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("malformed membership annotation %q, expected %s<id>.<role>", key, prefix)
+	}
+	if errs := validation.IsDNS1123Label(parts[1]); len(errs) > 0 {
+		return "", "", fmt.Errorf("malformed membership annotation %q: invalid role %q: %s", key, parts[1], strings.Join(errs, ", "))
+	}
+	return parts[0], parts[1], nil
+}
+
+// isDefaultWorkspace reports whether name is one of the workspaces Rancher manages itself.
+func isDefaultWorkspace(name string) bool {
+	return name == "fleet-default" || name == "fleet-local"
+}
+
+// annotationAdmins counts the membership annotations granting the admin role.
+func annotationAdmins(annotations map[string]string) int {
+	admins := 0
+	for k := range annotations {
+		prefix := membershipPrefix(k)
+		if prefix == "" {
+			continue
+		}
+		if _, role, err := parseMembershipKey(prefix, k); err == nil && role == adminRole {
+			admins++
+		}
+	}
+	return admins
+}
+
+// permanentAdmins counts the admin annotations without a gorizond-expires. annotation.
+func permanentAdmins(annotations map[string]string) int {
+	admins := 0
+	for k := range annotations {
+		prefix := membershipPrefix(k)
+		if prefix == "" {
+			continue
+		}
+		if _, expires := annotations[membershipExpiryPrefix+k]; expires {
+			continue
+		}
+		if _, role, err := parseMembershipKey(prefix, k); err == nil && role == adminRole {
+			admins++
+		}
+	}
+	return admins
+}
+
+// ValidateFleetWorkspace checks a workspace the way the controllers read it. old is nil on
+// create. Only membership and expiry annotations added or changed by the request are checked, so a
+// role removed from the catalog does not block unrelated updates, and an update may neither take
+// away the last admin annotation nor let every admin annotation expire unless otherAdmins grant
+// admin by other means.
+func ValidateFleetWorkspace(old, obj *managementv3.FleetWorkspace, catalog *RoleCatalog, otherAdmins int) field.ErrorList {
+	var errs field.ErrorList
+	if isDefaultWorkspace(obj.Name) {
+		return nil
+	}
+
+	if old == nil && !strings.HasPrefix(obj.Name, workspacePrefix) {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), obj.Name,
+			fmt.Sprintf("fleet workspace names must start with %q", workspacePrefix)))
+	}
+
+	annotations := field.NewPath("metadata", "annotations")
+	for k, v := range obj.Annotations {
+		if old != nil {
+			if previous, ok := old.Annotations[k]; ok && previous == v {
+				continue
+			}
+		}
+		if member, ok := strings.CutPrefix(k, membershipExpiryPrefix); ok {
+			if _, err := parseMembershipExpiry(v, time.Now()); err != nil {
+				errs = append(errs, field.Invalid(annotations.Key(k), v, err.Error()))
+			}
+			if _, ok := obj.Annotations[member]; !ok {
+				errs = append(errs, field.Invalid(annotations.Key(k), v, fmt.Sprintf("no membership annotation %q to expire", member)))
+			}
+			continue
+		}
+		prefix := membershipPrefix(k)
+		if prefix == "" {
+			continue
+		}
+		_, role, err := parseMembershipKey(prefix, k)
+		if err != nil {
+			errs = append(errs, field.Invalid(annotations.Key(k), v, err.Error()))
+			continue
+		}
+		if _, ok := catalog.Role(role); !ok {
+			errs = append(errs, field.NotSupported(annotations.Key(k), role, catalogRoleNames(catalog)))
+		}
+		if prefix == "gorizond-principal." && !strings.Contains(v, "://") {
+			errs = append(errs, field.Invalid(annotations.Key(k), v, "expected a principal ID like github_user://123"))
+		}
+	}
+
+	if old != nil && annotationAdmins(old.Annotations) > 0 && annotationAdmins(obj.Annotations) == 0 && otherAdmins == 0 {
+		errs = append(errs, field.Forbidden(annotations, "the update removes the last admin of the workspace"))
+	} else if old != nil && permanentAdmins(old.Annotations) > 0 && permanentAdmins(obj.Annotations) == 0 && otherAdmins == 0 {
+		// expiring the last admin would be refused by the rule above when it comes due
+		errs = append(errs, field.Forbidden(annotations, "every admin of the workspace would expire, keep one admin without an expiry"))
+	}
+	return errs
+}
+
+func catalogRoleNames(catalog *RoleCatalog) []string {
+	names := make([]string, 0, len(catalog.Roles))
+	for _, role := range catalog.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+// ValidateWorkspaceAccessRequest checks an access request made by username. old is nil on
+// create, admin tells whether username administers the requested workspace. Only admins
+// decide, a decision is final and the rest of the request cannot change once created.
+func ValidateWorkspaceAccessRequest(old, obj *workspacev1.WorkspaceAccessRequest, username string, admin bool, catalog *RoleCatalog) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	if old == nil {
+		if obj.Spec.Workspace == "" {
+			errs = append(errs, field.Required(spec.Child("workspace"), ""))
+		}
+		if _, ok := catalog.Role(obj.Spec.Role); !ok {
+			errs = append(errs, field.NotSupported(spec.Child("role"), obj.Spec.Role, catalogRoleNames(catalog)))
+		}
+		if obj.Spec.Requester == "" {
+			errs = append(errs, field.Required(spec.Child("requester"), ""))
+		}
+		if obj.Spec.Requester != "" && obj.Spec.Requester != username && !admin {
+			errs = append(errs, field.Forbidden(spec.Child("requester"), "only workspace admins may request access for someone else"))
+		}
+		if obj.Spec.ExpiresAfter != nil && obj.Spec.ExpiresAfter.Duration <= 0 {
+			errs = append(errs, field.Invalid(spec.Child("expiresAfter"), obj.Spec.ExpiresAfter.Duration.String(), "must be positive"))
+		}
+		if obj.Spec.AccessDuration != nil && obj.Spec.AccessDuration.Duration <= 0 {
+			errs = append(errs, field.Invalid(spec.Child("accessDuration"), obj.Spec.AccessDuration.Duration.String(), "must be positive"))
+		}
+	} else {
+		oldSpec, newSpec := old.Spec, obj.Spec
+		oldSpec.Decision, oldSpec.DecidedBy, newSpec.Decision, newSpec.DecidedBy = "", "", "", ""
+		if !equality.Semantic.DeepEqual(oldSpec, newSpec) {
+			errs = append(errs, field.Forbidden(spec, "only the decision of an access request can change"))
+		}
+		if old.Spec.Decision != "" && (old.Spec.Decision != obj.Spec.Decision || old.Spec.DecidedBy != obj.Spec.DecidedBy) {
+			errs = append(errs, field.Forbidden(spec.Child("decision"), "the request was already decided"))
+			return errs
+		}
+	}
+
+	if obj.Spec.Decision == "" || (old != nil && old.Spec.Decision == obj.Spec.Decision) {
+		return errs
+	}
+	switch obj.Spec.Decision {
+	case workspacev1.AccessRequestApproved, workspacev1.AccessRequestDenied:
+	default:
+		errs = append(errs, field.NotSupported(spec.Child("decision"), obj.Spec.Decision,
+			[]string{string(workspacev1.AccessRequestApproved), string(workspacev1.AccessRequestDenied)}))
+	}
+	if !admin {
+		errs = append(errs, field.Forbidden(spec.Child("decision"), "only workspace admins decide access requests"))
+	}
+	if obj.Spec.DecidedBy != username {
+		errs = append(errs, field.Invalid(spec.Child("decidedBy"), obj.Spec.DecidedBy, fmt.Sprintf("must be the deciding user %q", username)))
+	}
+	if obj.Spec.Requester == username && obj.Spec.Decision == workspacev1.AccessRequestApproved {
+		errs = append(errs, field.Forbidden(spec.Child("decision"), "requesters cannot approve their own request"))
+	}
+	return errs
+}
+
+// ValidateWorkspaceInvitation checks an invitation created by username. old is nil on create,
+// admin tells whether username administers the workspace. The spec cannot change once
+// created, a different invitee or role is a new invitation.
+func ValidateWorkspaceInvitation(old, obj *workspacev1.WorkspaceInvitation, username string, admin bool, catalog *RoleCatalog) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	if old != nil {
+		if !equality.Semantic.DeepEqual(old.Spec, obj.Spec) {
+			errs = append(errs, field.Forbidden(spec, "the invitation cannot change, create a new one"))
+		}
+		return errs
+	}
+
+	if !admin {
+		errs = append(errs, field.Forbidden(spec.Child("workspace"), "only workspace admins invite"))
+	}
+	if obj.Spec.InvitedBy != username {
+		errs = append(errs, field.Invalid(spec.Child("invitedBy"), obj.Spec.InvitedBy, fmt.Sprintf("must be the inviting user %q", username)))
+	}
+	if obj.Spec.Workspace == "" {
+		errs = append(errs, field.Required(spec.Child("workspace"), ""))
+	}
+	if _, ok := catalog.Role(obj.Spec.Role); !ok {
+		errs = append(errs, field.NotSupported(spec.Child("role"), obj.Spec.Role, catalogRoleNames(catalog)))
+	}
+	invitee := spec.Child("invitee")
+	switch obj.Spec.Invitee.Kind {
+	case workspacev1.InviteeKindLogin, workspacev1.InviteeKindPrincipal:
+	case workspacev1.InviteeKindEmail:
+		if obj.Spec.Invitee.Value != "" && !strings.Contains(obj.Spec.Invitee.Value, "@") {
+			errs = append(errs, field.Invalid(invitee.Child("value"), obj.Spec.Invitee.Value, "must be an email address"))
+		}
+	default:
+		errs = append(errs, field.NotSupported(invitee.Child("kind"), obj.Spec.Invitee.Kind, []string{
+			string(workspacev1.InviteeKindLogin), string(workspacev1.InviteeKindEmail), string(workspacev1.InviteeKindPrincipal),
+		}))
+	}
+	if obj.Spec.Invitee.Value == "" {
+		errs = append(errs, field.Required(invitee.Child("value"), ""))
+	}
+	if obj.Spec.ExpiresAfter != nil && obj.Spec.ExpiresAfter.Duration <= 0 {
+		errs = append(errs, field.Invalid(spec.Child("expiresAfter"), obj.Spec.ExpiresAfter.Duration.String(), "must be positive"))
+	}
+	return errs
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseMembershipKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		prefix   string
+		wantID   string
+		wantRole string
+		wantErr  bool
+	}{
+		{key: "gorizond-user.u-abc.admin", prefix: "gorizond-user.", wantID: "u-abc", wantRole: "admin"},
+		{key: "gorizond-group.p0123.view", prefix: "gorizond-group.", wantID: "p0123", wantRole: "view"},
+		{key: "gorizond-user.u-abc", prefix: "gorizond-user.", wantErr: true},
+		{key: "gorizond-user..admin", prefix: "gorizond-user.", wantErr: true},
+		{key: "gorizond-user.u-abc.", prefix: "gorizond-user.", wantErr: true},
+		{key: "gorizond-user.u-abc.Admin", prefix: "gorizond-user.", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			id, role, err := parseMembershipKey(tt.prefix, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if id != tt.wantID || role != tt.wantRole {
+				t.Fatalf("expected %q/%q, got %q/%q", tt.wantID, tt.wantRole, id, role)
+			}
+		})
+	}
+}
+
+func TestValidateFleetWorkspace(t *testing.T) {
+	oldPrefix := workspacePrefix
+	workspacePrefix = defaultWorkspacePrefix
+	t.Cleanup(func() { workspacePrefix = oldPrefix })
+
+	ws := func(name string, annotations map[string]string) *managementv3.FleetWorkspace {
+		return &managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+	admin := map[string]string{"gorizond-user.u-abc.admin": "local://u-abc"}
+
+	tests := []struct {
+		name        string
+		old         *managementv3.FleetWorkspace
+		obj         *managementv3.FleetWorkspace
+		otherAdmins int
+		wantErr     string
+	}{
+		{name: "valid create", obj: ws("workspace-a", admin)},
+		{name: "default workspace", obj: ws("fleet-default", map[string]string{"gorizond-user.u-abc": ""})},
+		{name: "wrong prefix", obj: ws("demo", nil), wantErr: "must start with"},
+		{name: "prefix is only checked on create", old: ws("demo", nil), obj: ws("demo", nil)},
+		{name: "missing role", obj: ws("workspace-a", map[string]string{"gorizond-user.u-abc": "local://u-abc"}), wantErr: "expected gorizond-user.<id>.<role>"},
+		{name: "unknown role", obj: ws("workspace-a", map[string]string{"gorizond-user.u-abc.owner": ""}), wantErr: "Unsupported value"},
+		{name: "principal without scheme", obj: ws("workspace-a", map[string]string{"gorizond-principal.x.view": "octocat"}), wantErr: "principal ID"},
+		{
+			name: "unchanged unknown role is kept",
+			old:  ws("workspace-a", map[string]string{"gorizond-user.u-abc.owner": "", "gorizond-user.u-abc.admin": ""}),
+			obj:  ws("workspace-a", map[string]string{"gorizond-user.u-abc.owner": "", "gorizond-user.u-abc.admin": "", "other": "x"}),
+		},
+		{name: "last admin removed", old: ws("workspace-a", admin), obj: ws("workspace-a", nil), wantErr: "last admin"},
+		{name: "admin kept by membership", old: ws("workspace-a", admin), obj: ws("workspace-a", nil), otherAdmins: 1},
+		{
+			name: "membership expiry",
+			obj:  ws("workspace-a", map[string]string{"gorizond-user.u-abc.admin": "", "gorizond-expires.gorizond-user.u-abc.admin": "7d"}),
+		},
+		{
+			name:    "invalid expiry",
+			obj:     ws("workspace-a", map[string]string{"gorizond-user.u-abc.admin": "", "gorizond-expires.gorizond-user.u-abc.admin": "soon"}),
+			wantErr: "positive duration",
+		},
+		{
+			name:    "expiry without membership",
+			obj:     ws("workspace-a", map[string]string{"gorizond-user.u-abc.admin": "", "gorizond-expires.gorizond-user.u-xyz.view": "7d"}),
+			wantErr: "no membership annotation",
+		},
+		{
+			name:    "last admin expiring",
+			old:     ws("workspace-a", admin),
+			obj:     ws("workspace-a", map[string]string{"gorizond-user.u-abc.admin": "local://u-abc", "gorizond-expires.gorizond-user.u-abc.admin": "7d"}),
+			wantErr: "every admin of the workspace would expire",
+		},
+		{
+			name:        "expiring admin kept by membership",
+			old:         ws("workspace-a", admin),
+			obj:         ws("workspace-a", map[string]string{"gorizond-user.u-abc.admin": "local://u-abc", "gorizond-expires.gorizond-user.u-abc.admin": "7d"}),
+			otherAdmins: 1,
+		},
+		{
+			name: "expiring admin next to a permanent one",
+			old:  ws("workspace-a", map[string]string{"gorizond-user.u-abc.admin": "local://u-abc", "gorizond-user.u-xyz.admin": "local://u-xyz"}),
+			obj: ws("workspace-a", map[string]string{
+				"gorizond-user.u-abc.admin":                  "local://u-abc",
+				"gorizond-user.u-xyz.admin":                  "local://u-xyz",
+				"gorizond-expires.gorizond-user.u-xyz.admin": "7d",
+			}),
+		},
+		{
+			name:    "permanent admin removed next to an expiring one",
+			old:     ws("workspace-a", map[string]string{"gorizond-user.u-abc.admin": "local://u-abc", "gorizond-user.u-xyz.admin": "local://u-xyz", "gorizond-expires.gorizond-user.u-xyz.admin": "7d"}),
+			obj:     ws("workspace-a", map[string]string{"gorizond-user.u-xyz.admin": "local://u-xyz", "gorizond-expires.gorizond-user.u-xyz.admin": "7d"}),
+			wantErr: "every admin of the workspace would expire",
+		},
+		{
+			name: "expiry normalized on an expiring admin",
+			old:  ws("workspace-a", map[string]string{"gorizond-user.u-abc.admin": "local://u-abc", "gorizond-expires.gorizond-user.u-abc.admin": "7d"}),
+			obj:  ws("workspace-a", map[string]string{"gorizond-user.u-abc.admin": "local://u-abc", "gorizond-expires.gorizond-user.u-abc.admin": "2030-01-01T00:00:00Z"}),
+		},
+		{
+			name: "admin handed over",
+			old:  ws("workspace-a", admin),
+			obj:  ws("workspace-a", map[string]string{"gorizond-group.p1.admin": "github_org://1"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateFleetWorkspace(tt.old, tt.obj, defaultRoleCatalog(), tt.otherAdmins)
+			if tt.wantErr == "" {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if !strings.Contains(errs.ToAggregate().Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	v3 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io/v3"
+	workspace "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io"
+	workspacecontrollers "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io/v1"
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
+	"github.com/rancher/lasso/pkg/log"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// membershipLabel marks GlobalRoleBindings owned by a WorkspaceMembership,
+	// membershipAnnotation holds the owning membership name.
+	membershipLabel       = "gorizond-membership"
+	membershipAnnotation  = "gorizond-membership"
+	membershipByWorkspace = "gorizond-membership-by-workspace"
+)
+
+var membershipBound = condition.Cond("Bound")
+
+type membershipHandler struct {
+	memberships        workspacecontrollers.WorkspaceMembershipController
+	fleetWorkspaces    v3.FleetWorkspaceCache
+	users              v3.UserCache
+	globalRoles        v3.GlobalRoleCache
+	globalRoleBindings v3.GlobalRoleBindingController
+	configMaps         configMapReader
+	resolvePrincipal   func(principalID string, requested time.Time) (string, bool, error)
+}
+
+// InitWorkspaceMembershipController drives GlobalRoleBindings from WorkspaceMembership objects.
+// Legacy gorizond-user./gorizond-group. annotations keep working next to it.
+func InitWorkspaceMembershipController(ctx context.Context, mgmt *management.Factory, ws *workspace.Factory, configMaps corecontrollers.ConfigMapCache, rancher RancherAPI) {
+	memberships := ws.Workspace().V1().WorkspaceMembership()
+	fleetWorkspaces := mgmt.Management().V3().FleetWorkspace()
+	globalRoles := mgmt.Management().V3().GlobalRole()
+	globalRoleBindings := mgmt.Management().V3().GlobalRoleBinding()
+
+	memberships.Cache().AddIndexer(membershipByWorkspace, func(obj *workspacev1.WorkspaceMembership) ([]string, error) {
+		return []string{obj.Spec.Workspace}, nil
+	})
+
+	users := mgmt.Management().V3().User()
+	provisioner := newUserProvisioner(rancher, users)
+
+	h := &membershipHandler{
+		memberships:        memberships,
+		fleetWorkspaces:    fleetWorkspaces.Cache(),
+		users:              users.Cache(),
+		globalRoles:        globalRoles.Cache(),
+		globalRoleBindings: globalRoleBindings,
+		configMaps:         configMaps,
+		resolvePrincipal: func(principalID string, requested time.Time) (string, bool, error) {
+			return provisioner.resolve(ctx, principalID, requested)
+		},
+	}
+	workspacecontrollers.RegisterWorkspaceMembershipStatusHandler(ctx, memberships, membershipBound, "gorizond-workspace-membership-controller",
+		func(obj *workspacev1.WorkspaceMembership, status workspacev1.WorkspaceMembershipStatus) (workspacev1.WorkspaceMembershipStatus, error) {
+			start := time.Now()
+			status, err := h.sync(obj, status)
+			metrics.ObserveReconcile("gorizond-workspace-membership-controller", start, err)
+			return status, err
+		})
+
+	// workspaces and their roles appearing, and bindings being removed, re-trigger memberships
+	relatedresource.WatchClusterScoped(ctx, "gorizond-workspace-membership-watch", func(_, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+		return resolveMemberships(memberships.Cache(), name, obj)
+	}, memberships, fleetWorkspaces, globalRoles, globalRoleBindings)
+}
+
+func resolveMemberships(memberships workspacecontrollers.WorkspaceMembershipCache, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	meta, err := apimeta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceName := name
+	switch obj.(type) {
+	case *managementv3.GlobalRoleBinding:
+		if owner := meta.GetAnnotations()[membershipAnnotation]; owner != "" {
+			return []relatedresource.Key{{Name: owner}}, nil
+		}
+		return nil, nil
+	case *managementv3.GlobalRole:
+		workspaceName = meta.GetLabels()["fleet"]
+		if workspaceName == "" {
+			return nil, nil
+		}
+	}
+
+	owned, err := memberships.GetByIndex(membershipByWorkspace, workspaceName)
+	if err != nil {
+		return nil, err
+	}
+	var keys []relatedresource.Key
+	for _, membership := range owned {
+		keys = append(keys, relatedresource.Key{Name: membership.Name})
+	}
+	return keys, nil
+}
+
+func (h *membershipHandler) sync(obj *workspacev1.WorkspaceMembership, status workspacev1.WorkspaceMembershipStatus) (workspacev1.WorkspaceMembershipStatus, error) {
+	if obj.DeletionTimestamp != nil {
+		return status, nil
+	}
+
+	desired, resolvedUser, err := h.desiredBinding(obj)
+	if err != nil {
+		var pendingErr *userPendingError
+		if goerrors.As(err, &pendingErr) {
+			h.memberships.EnqueueAfter(obj.Name, userProvisioningRetry)
+			err = goerrors.Join(err, h.setProvisioningRequested(obj, pendingErr.requested.UTC().Format(time.RFC3339)))
+		}
+		return status, err
+	}
+
+	if err := ensureGlobalRoleBinding(h.globalRoleBindings, desired); err != nil {
+		return status, err
+	}
+	if err := h.setProvisioningRequested(obj, ""); err != nil {
+		return status, err
+	}
+
+	status.GlobalRoleBindingName = desired.Name
+	status.ResolvedUser = resolvedUser
+	return status, nil
+}
+
+// setProvisioningRequested records when the User of the principal subject was first
+// requested, or removes the record when value is empty. A recorded time is kept.
+func (h *membershipHandler) setProvisioningRequested(obj *workspacev1.WorkspaceMembership, value string) error {
+	key := provisioningAnnotation(obj.Spec.Subject.Name)
+	if _, ok := obj.Annotations[key]; ok == (value != "") {
+		return nil
+	}
+	obj = obj.DeepCopy()
+	if value == "" {
+		delete(obj.Annotations, key)
+	} else {
+		if obj.Annotations == nil {
+			obj.Annotations = map[string]string{}
+		}
+		obj.Annotations[key] = value
+	}
+	_, err := h.memberships.Update(obj)
+	return err
+}
+
+// desiredBinding validates the membership and builds the GlobalRoleBinding it asks for.
+func (h *membershipHandler) desiredBinding(obj *workspacev1.WorkspaceMembership) (*managementv3.GlobalRoleBinding, string, error) {
+	workspaceName := obj.Spec.Workspace
+	if _, err := h.fleetWorkspaces.Get(workspaceName); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, "", fmt.Errorf("workspace %q not found", workspaceName)
+		}
+		return nil, "", err
+	}
+
+	catalog, err := roleCatalog.read(h.configMaps)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, ok := catalog.Role(obj.Spec.Role); !ok {
+		return nil, "", fmt.Errorf("role %q is not in the role catalog", obj.Spec.Role)
+	}
+	roleName := globalRoleName(obj.Spec.Role, workspaceName)
+	if _, err := h.globalRoles.Get(roleName); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, "", fmt.Errorf("global role %q not created yet", roleName)
+		}
+		return nil, "", err
+	}
+
+	binding := &managementv3.GlobalRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "gorizond-membership-" + obj.Name,
+			Labels: map[string]string{
+				"fleet":         workspaceName,
+				membershipLabel: "true",
+			},
+			Annotations: map[string]string{
+				membershipAnnotation: obj.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: workspacev1.SchemeGroupVersion.String(),
+				Kind:       "WorkspaceMembership",
+				Name:       obj.Name,
+				UID:        obj.UID,
+			}},
+		},
+		GlobalRoleName: roleName,
+	}
+
+	subject := obj.Spec.Subject
+	if subject.Name == "" {
+		return nil, "", fmt.Errorf("subject name is required")
+	}
+	switch subject.Kind {
+	case workspacev1.SubjectKindUser:
+		if _, err := h.users.Get(subject.Name); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, "", fmt.Errorf("user %q not found", subject.Name)
+			}
+			return nil, "", err
+		}
+		binding.UserName = subject.Name
+		return binding, subject.Name, nil
+	case workspacev1.SubjectKindGroup:
+		binding.GroupPrincipalName = subject.Name
+		return binding, "", nil
+	case workspacev1.SubjectKindPrincipal:
+		userID, isGroup, err := h.resolvePrincipal(subject.Name, provisioningRequested(obj.Annotations, subject.Name))
+		if err != nil {
+			return nil, "", err
+		}
+		if isGroup {
+			binding.GroupPrincipalName = subject.Name
+			return binding, "", nil
+		}
+		if userID == "" {
+			return nil, "", fmt.Errorf("no Rancher user found for principal %q", subject.Name)
+		}
+		binding.UserName = userID
+		binding.UserPrincipalName = subject.Name
+		return binding, userID, nil
+	default:
+		return nil, "", fmt.Errorf("unknown subject kind %q", subject.Kind)
+	}
+}
+
+// ensureGlobalRoleBinding creates the binding, replacing an existing one whose
+// immutable subject or role no longer matches.
+func ensureGlobalRoleBinding(globalRoleBindings v3.GlobalRoleBindingController, desired *managementv3.GlobalRoleBinding) error {
+	existing, err := globalRoleBindings.Cache().Get(desired.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		if existing.DeletionTimestamp != nil {
+			return fmt.Errorf("waiting for global role binding %s to be removed", desired.Name)
+		}
+		if existing.GlobalRoleName == desired.GlobalRoleName &&
+			existing.UserName == desired.UserName &&
+			existing.UserPrincipalName == desired.UserPrincipalName &&
+			existing.GroupPrincipalName == desired.GroupPrincipalName {
+			return nil
+		}
+		log.Infof("Replacing global role binding %s with changed subject or role", desired.Name)
+		if err := globalRoleBindings.Delete(desired.Name, nil); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	if _, err := globalRoleBindings.Create(desired); err != nil {
+		if errors.IsAlreadyExists(err) {
+			return fmt.Errorf("waiting for global role binding %s to be removed", desired.Name)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeCache is an in-memory generic.NonNamespacedCacheInterface keyed by object name.
+type fakeCache[T runtime.Object] struct {
+	objs     map[string]T
+	indexers map[string]generic.Indexer[T]
+}
+
+func newFakeCache[T runtime.Object](objs ...T) *fakeCache[T] {
+	c := &fakeCache[T]{objs: map[string]T{}, indexers: map[string]generic.Indexer[T]{}}
+	for _, obj := range objs {
+		meta, _ := apimeta.Accessor(obj)
+		c.objs[meta.GetName()] = obj
+	}
+	return c
+}
+
+func (c *fakeCache[T]) Get(name string) (T, error) {
+	obj, ok := c.objs[name]
+	if !ok {
+		return obj, errors.NewNotFound(schema.GroupResource{}, name)
+	}
+	return obj, nil
+}
+
+func (c *fakeCache[T]) List(selector labels.Selector) ([]T, error) {
+	var result []T
+	for _, obj := range c.objs {
+		meta, _ := apimeta.Accessor(obj)
+		if selector.Matches(labels.Set(meta.GetLabels())) {
+			result = append(result, obj)
+		}
+	}
+	return result, nil
+}
+
+func (c *fakeCache[T]) AddIndexer(indexName string, indexer generic.Indexer[T]) {
+	c.indexers[indexName] = indexer
+}
+
+func (c *fakeCache[T]) GetByIndex(indexName, key string) ([]T, error) {
+	var result []T
+	for _, obj := range c.objs {
+		keys, err := c.indexers[indexName](obj)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if k == key {
+				result = append(result, obj)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func TestMembershipDesiredBinding(t *testing.T) {
+	h := &membershipHandler{
+		fleetWorkspaces: newFakeCache(&managementv3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: "workspace-demo"}}),
+		users:           newFakeCache(&managementv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-abc"}}),
+		globalRoles:     newFakeCache(&managementv3.GlobalRole{ObjectMeta: metav1.ObjectMeta{Name: "gorizond-editor-workspace-demo"}}),
+		configMaps:      fakeConfigMaps{},
+		resolvePrincipal: func(principalID string, _ time.Time) (string, bool, error) {
+			switch principalID {
+			case "github_org://42":
+				return "", true, nil
+			case "github_user://7":
+				return "u-abc", false, nil
+			}
+			return "", false, nil
+		},
+	}
+
+	membership := func(kind workspacev1.SubjectKind, name, role, ws string) *workspacev1.WorkspaceMembership {
+		return &workspacev1.WorkspaceMembership{
+			ObjectMeta: metav1.ObjectMeta{Name: "m1", UID: "uid-1"},
+			Spec: workspacev1.WorkspaceMembershipSpec{
+				Workspace: ws,
+				Role:      role,
+				Subject:   workspacev1.Subject{Kind: kind, Name: name},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		obj       *workspacev1.WorkspaceMembership
+		wantErr   bool
+		wantUser  string
+		wantGroup string
+		wantLocal string
+	}{
+		{name: "user", obj: membership(workspacev1.SubjectKindUser, "u-abc", "editor", "workspace-demo"), wantUser: "u-abc", wantLocal: "u-abc"},
+		{name: "group", obj: membership(workspacev1.SubjectKindGroup, "github_org://42", "editor", "workspace-demo"), wantGroup: "github_org://42"},
+		{name: "principal user", obj: membership(workspacev1.SubjectKindPrincipal, "github_user://7", "editor", "workspace-demo"), wantUser: "u-abc", wantLocal: "u-abc"},
+		{name: "principal group", obj: membership(workspacev1.SubjectKindPrincipal, "github_org://42", "editor", "workspace-demo"), wantGroup: "github_org://42"},
+		{name: "unresolved principal", obj: membership(workspacev1.SubjectKindPrincipal, "github_user://8", "editor", "workspace-demo"), wantErr: true},
+		{name: "unknown user", obj: membership(workspacev1.SubjectKindUser, "u-missing", "editor", "workspace-demo"), wantErr: true},
+		{name: "unknown workspace", obj: membership(workspacev1.SubjectKindUser, "u-abc", "editor", "workspace-missing"), wantErr: true},
+		{name: "unknown role", obj: membership(workspacev1.SubjectKindUser, "u-abc", "owner", "workspace-demo"), wantErr: true},
+		{name: "unknown kind", obj: membership("Robot", "r2d2", "editor", "workspace-demo"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binding, resolved, err := h.desiredBinding(tt.obj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if binding.UserName != tt.wantUser || binding.GroupPrincipalName != tt.wantGroup {
+				t.Fatalf("unexpected subject user=%q group=%q", binding.UserName, binding.GroupPrincipalName)
+			}
+			if resolved != tt.wantLocal {
+				t.Fatalf("expected resolved user %q, got %q", tt.wantLocal, resolved)
+			}
+			if binding.GlobalRoleName != "gorizond-editor-workspace-demo" {
+				t.Fatalf("unexpected global role %q", binding.GlobalRoleName)
+			}
+			if binding.Labels["fleet"] != "workspace-demo" || binding.Labels[membershipLabel] != "true" {
+				t.Fatalf("unexpected labels %v", binding.Labels)
+			}
+			if len(binding.OwnerReferences) != 1 || binding.OwnerReferences[0].UID != "uid-1" {
+				t.Fatalf("expected owner reference to the membership, got %v", binding.OwnerReferences)
+			}
+		})
+	}
+}
@@ -1,17 +1,34 @@
 package main
 
 import (
+    "context"
     "flag"
     "net/http"
     "os"
+    "sync/atomic"
+    "time"
 
     "github.com/gorizond/fleet-workspace-controller/controllers"
+    "github.com/gorizond/fleet-workspace-controller/pkg/crds"
     "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+    "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io"
+    "github.com/gorizond/fleet-workspace-controller/pkg/health"
+    "github.com/gorizond/fleet-workspace-controller/pkg/leader"
+    "github.com/gorizond/fleet-workspace-controller/pkg/metrics"
+    "github.com/gorizond/fleet-workspace-controller/pkg/rancherapi"
+    "github.com/gorizond/fleet-workspace-controller/pkg/webhook"
     "github.com/rancher/lasso/pkg/log"
+    "github.com/rancher/wrangler/v3/pkg/apply"
+    "github.com/rancher/wrangler/v3/pkg/generated/controllers/core"
     "github.com/rancher/wrangler/v3/pkg/kubeconfig"
+    "github.com/rancher/wrangler/v3/pkg/schemes"
     "github.com/rancher/wrangler/v3/pkg/signals"
     "github.com/rancher/wrangler/v3/pkg/start"
+    corev1 "k8s.io/api/core/v1"
+    "k8s.io/client-go/kubernetes"
+    typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
     "k8s.io/client-go/rest"
+    "k8s.io/client-go/tools/record"
     "k8s.io/klog/v2"
 )
 
@@ -31,6 +48,30 @@ func (w *warnLoggingRT) RoundTrip(req *http.Request) (*http.Response, error) {
     return resp, nil
 }
 
+// newEventRecorder returns a recorder publishing Events on behalf of the controller.
+func newEventRecorder(ctx context.Context, config *rest.Config) record.EventRecorder {
+    clientset := kubernetes.NewForConfigOrDie(config)
+    broadcaster := record.NewBroadcaster(record.WithContext(ctx))
+    broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+    return broadcaster.NewRecorder(schemes.All, corev1.EventSource{Component: "fleet-workspace-controller"})
+}
+
+// serveHTTP runs the metrics and probe endpoints until ctx is done.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) {
+    server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+    go func() {
+        <-ctx.Done()
+        shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+        defer cancel()
+        _ = server.Shutdown(shutdownCtx)
+    }()
+    go func() {
+        if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            log.Errorf("HTTP server on %s stopped: %v", addr, err)
+        }
+    }()
+}
+
 func init() {
     // Emit server warnings with context to pinpoint `unknown field "spec"` origin.
     klog.InitFlags(nil)
@@ -41,8 +82,25 @@ func init() {
 }
 
 func main() {
+    // memberships export/import is a one-shot tool sharing the binary, not the controller
+    if len(os.Args) > 1 && os.Args[1] == "memberships" {
+        os.Exit(runMemberships(os.Args[2:], os.Stdout, os.Stderr))
+    }
+
     var kubeconfig_file string
+    var metricsAddr string
+    var readyzCheckRancher bool
+    var livenessTimeout time.Duration
+    var webhookAddr string
+    var webhookCertDir string
     flag.StringVar(&kubeconfig_file, "kubeconfig", "", "Path to kubeconfig")
+    flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "Address serving /metrics, /healthz and /readyz, empty to disable")
+    flag.BoolVar(&readyzCheckRancher, "readyz-check-rancher", false, "Fail /readyz while RANCHER_URL does not answer with RANCHER_TOKEN")
+    flag.DurationVar(&livenessTimeout, "liveness-timeout", 5*time.Minute, "Fail /healthz when the FleetWorkspace workers made no progress for this long")
+    flag.StringVar(&webhookAddr, "webhook-bind-address", "", "Address serving the validating webhooks over TLS, empty to disable")
+    flag.StringVar(&webhookCertDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs", "Directory holding tls.crt and tls.key of the webhook server")
+    leaderElection := leader.DefaultOptions(controllers.ConfigNamespace())
+    leaderElection.AddFlags(flag.CommandLine)
     flag.Parse()
 
     config, err := rest.InClusterConfig()
@@ -68,17 +126,100 @@ func main() {
         log.Errorf("Failed to create management factory: %v", err)
     }
 
+    // ConfigMaps are only watched in the controller namespace
+    coreFactory, err := core.NewFactoryFromConfigWithNamespace(config, controllers.ConfigNamespace())
+    if err != nil {
+        log.Errorf("Failed to create core factory: %v", err)
+    }
+
+    workspaceFactory, err := workspace.NewFactoryFromConfig(config)
+    if err != nil {
+        log.Errorf("Failed to create workspace factory: %v", err)
+    }
+
+    // Applies WorkspaceTemplate resources into workspace namespaces
+    applier, err := apply.NewForConfig(config)
+    if err != nil {
+        log.Errorf("Failed to create apply client: %v", err)
+    }
+
+    rancherClient, err := rancherapi.New(rancherapi.OptionsFromEnv())
+    if err != nil {
+        panic(err)
+    }
+
+    // Standbys report ready so rolling updates can proceed, only the leader waits for its caches
+    var leading atomic.Bool
+    cachesSynced := controllers.CacheSyncCheck(factory)
+    heartbeat := health.NewHeartbeat(livenessTimeout)
+    checks := &health.Checks{}
+    checks.AddLive("fleetworkspace-queue", heartbeat.Check)
+    checks.AddReady("caches", func(ctx context.Context) error {
+        if !leading.Load() {
+            return nil
+        }
+        return cachesSynced(ctx)
+    })
+    if readyzCheckRancher {
+        checks.AddReady("rancher", rancherClient.Ping)
+    }
+
     ctx := signals.SetupSignalContext()
+    if metricsAddr != "" {
+        mux := http.NewServeMux()
+        mux.Handle("/metrics", metrics.Handler())
+        checks.Install(mux)
+        serveHTTP(ctx, metricsAddr, mux)
+    }
+    if err := crds.Create(ctx, config); err != nil {
+        panic(err)
+    }
+    // Every replica validates, the webhook does not depend on the leader's caches
+    if webhookAddr != "" {
+        mux := http.NewServeMux()
+        mux.Handle("/validate-fleetworkspace", webhook.Handler(controllers.FleetWorkspaceValidator(
+            coreFactory.Core().V1().ConfigMap(),
+            workspaceFactory.Workspace().V1().WorkspaceMembership(),
+        )))
+        mux.Handle("/validate-workspaceaccessrequest", webhook.Handler(controllers.WorkspaceAccessRequestValidator(
+            coreFactory.Core().V1().ConfigMap(),
+            factory.Management().V3().FleetWorkspace(),
+            workspaceFactory.Workspace().V1().WorkspaceMembership(),
+        )))
+        mux.Handle("/validate-workspaceinvitation", webhook.Handler(controllers.WorkspaceInvitationValidator(
+            coreFactory.Core().V1().ConfigMap(),
+            factory.Management().V3().FleetWorkspace(),
+            workspaceFactory.Workspace().V1().WorkspaceMembership(),
+        )))
+        if err := webhook.Serve(ctx, webhookAddr, webhookCertDir, mux); err != nil {
+            panic(err)
+        }
+    }
     // Initialize controllers
-    controllers.InitUserController(ctx, factory)
-    controllers.InitFleetWorkspaceController(ctx, factory)
+    recorder := newEventRecorder(ctx, config)
+    configMaps := coreFactory.Core().V1().ConfigMap()
+    invitations := controllers.InitWorkspaceInvitationController(ctx, factory, workspaceFactory, configMaps.Cache(), recorder)
+    controllers.InitUserController(ctx, factory, configMaps.Cache(), invitations)
+    controllers.InitFleetWorkspaceController(ctx, factory, workspaceFactory, configMaps.Cache(), applier, recorder, rancherClient)
     controllers.InitGlobalRoleBindingController(ctx, factory)
-    controllers.InitGlobalRoleBindingTTLController(ctx, factory)
+    controllers.InitGlobalRoleBindingTTLController(ctx, factory, recorder)
+    controllers.InitRoleCatalogController(ctx, factory, configMaps)
+    controllers.InitWorkspacePolicyController(ctx, factory, coreFactory.Core().V1().ConfigMap())
+    controllers.InitWorkspaceQuotaController(ctx, coreFactory.Core().V1().ConfigMap())
+    controllers.InitWorkspaceMembershipController(ctx, factory, workspaceFactory, configMaps.Cache(), rancherClient)
+    controllers.InitWorkspaceAccessRequestController(ctx, factory, workspaceFactory, configMaps.Cache(), recorder)
     // controllers.InitUserWorkspaceGuard(ctx, factory)
-    // Start controllers
-    if err := start.All(ctx, 10, factory); err != nil {
+    controllers.InitQueueHeartbeat(ctx, factory, heartbeat)
+    // Start controllers once this replica is the leader
+    err = leader.Run(ctx, config, leaderElection, func(ctx context.Context) {
+        leading.Store(true)
+        if err := start.All(ctx, 10, factory, coreFactory, workspaceFactory); err != nil {
+            panic(err)
+        }
+        go controllers.RunQueueHeartbeat(ctx, factory, heartbeat, livenessTimeout/10)
+        <-ctx.Done()
+    })
+    if err != nil {
         panic(err)
     }
-
-    <-ctx.Done()
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorizond/fleet-workspace-controller/controllers"
+	managementv3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/management.cattle.io"
+	"github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io"
+	"github.com/rancher/wrangler/v3/pkg/generated/controllers/core"
+	"github.com/rancher/wrangler/v3/pkg/kubeconfig"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const membershipsUsage = `Usage:
+  fleet-workspace-controller memberships export [--workspace NAME]... [--format yaml|csv]
+  fleet-workspace-controller memberships import -f FILE [--format yaml|csv] [--apply]
+
+export prints the effective memberships of the given workspaces, or of all of them.
+import compares a document with the membership annotations of the workspaces it names
+and prints the diff, --apply also patches the workspaces. The role catalog is read like
+the controller does, from CONFIG_NAMESPACE and ROLE_CATALOG_CONFIGMAP.
+`
+
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// runMemberships runs the memberships subcommand and returns the exit code.
+func runMemberships(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		fmt.Fprint(stderr, membershipsUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("memberships "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	kubeconfigFile := flags.String("kubeconfig", "", "Path to kubeconfig")
+	format := flags.String("format", "", "Document format, yaml or csv (default: from the file extension, else yaml)")
+	file := flags.String("f", "", "Document to import, - for stdin")
+	apply := flags.Bool("apply", false, "Patch the workspaces instead of only printing the diff")
+	var workspaces stringList
+	flags.Var(&workspaces, "workspace", "Workspace to export, repeatable (default: all)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if err := membershipsCommand(args[0], *kubeconfigFile, *format, *file, *apply, workspaces, stdout); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func membershipsCommand(command, kubeconfigFile, format, file string, apply bool, workspaces []string, stdout io.Writer) error {
+	if format == "" {
+		format = "yaml"
+		if strings.EqualFold(filepath.Ext(file), ".csv") {
+			format = "csv"
+		}
+	}
+	if command == "import" && file == "" {
+		return fmt.Errorf("import needs -f FILE")
+	}
+
+	config, err := kubeconfig.GetNonInteractiveClientConfig(kubeconfigFile).ClientConfig()
+	if err != nil {
+		return err
+	}
+	mgmt, err := management.NewFactoryFromConfig(config)
+	if err != nil {
+		return err
+	}
+	ws, err := workspace.NewFactoryFromConfig(config)
+	if err != nil {
+		return err
+	}
+	fleetWorkspaces := mgmt.Management().V3().FleetWorkspace()
+	memberships := ws.Workspace().V1().WorkspaceMembership()
+
+	if command == "export" {
+		membershipList, err := memberships.List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		var targets []managementv3.FleetWorkspace
+		if len(workspaces) == 0 {
+			list, err := fleetWorkspaces.List(metav1.ListOptions{})
+			if err != nil {
+				return err
+			}
+			targets = list.Items
+		}
+		for _, name := range workspaces {
+			obj, err := fleetWorkspaces.Get(name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			targets = append(targets, *obj)
+		}
+		var records []controllers.MembershipRecord
+		for i := range targets {
+			records = append(records, controllers.ExportMemberships(&targets[i], membershipList.Items)...)
+		}
+		return controllers.EncodeMemberships(stdout, records, format)
+	}
+
+	in := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	records, err := controllers.DecodeMemberships(in, format)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	coreFactory, err := core.NewFactoryFromConfigWithNamespace(config, controllers.ConfigNamespace())
+	if err != nil {
+		return err
+	}
+	plans, err := controllers.PlanMembershipImports(records, fleetWorkspaces, memberships, coreFactory.Core().V1().ConfigMap())
+	if err != nil {
+		return err
+	}
+
+	for _, plan := range plans {
+		if err := plan.WriteDiff(stdout); err != nil {
+			return err
+		}
+	}
+	if !apply {
+		return nil
+	}
+	for _, plan := range plans {
+		if len(plan.Changes) == 0 {
+			continue
+		}
+		patch, err := plan.Patch()
+		if err != nil {
+			return err
+		}
+		if _, err := fleetWorkspaces.Patch(plan.Workspace, types.MergePatchType, patch); err != nil {
+			return fmt.Errorf("failed to patch workspace %s: %w", plan.Workspace, err)
+		}
+		fmt.Fprintf(stdout, "%s: applied %d changes\n", plan.Workspace, len(plan.Changes))
+	}
+	return nil
+}
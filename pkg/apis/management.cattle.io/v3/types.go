@@ -59,4 +59,11 @@ type GlobalRole rancherv3.GlobalRole
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // GlobalRole is a wrapper around rancher type
-type Principal rancherv3.Principal
\ No newline at end of file
+type Principal rancherv3.Principal
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserAttribute is a wrapper around rancher type
+type UserAttribute rancherv3.UserAttribute
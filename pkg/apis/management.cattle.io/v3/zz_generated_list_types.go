@@ -92,3 +92,20 @@ func NewPrincipal(namespace, name string, obj Principal) *Principal {
 	obj.Namespace = namespace
 	return &obj
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserAttributeList is a list of UserAttribute resources
+type UserAttributeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserAttribute `json:"items"`
+}
+
+func NewUserAttribute(namespace, name string, obj UserAttribute) *UserAttribute {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserAttribute").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
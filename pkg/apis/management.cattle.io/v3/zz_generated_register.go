@@ -17,6 +17,7 @@ var (
 	GlobalRoleBindingResourceName = "globalrolebindings"
 	PrincipalResourceName         = "principals"
 	UserResourceName              = "users"
+	UserAttributeResourceName     = "userattributes"
 )
 
 // SchemeGroupVersion is group version used to register these objects
@@ -50,6 +51,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&PrincipalList{},
 		&User{},
 		&UserList{},
+		&UserAttribute{},
+		&UserAttributeList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -0,0 +1,261 @@
+package v1
+
+import (
+	fleetv1 "github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	"github.com/rancher/wrangler/v3/pkg/genericcondition"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SubjectKind is the kind of subject a membership grants access to.
+type SubjectKind string
+
+const (
+	// SubjectKindUser binds a Rancher user by its ID (e.g. u-abc12).
+	SubjectKindUser SubjectKind = "User"
+	// SubjectKindGroup binds a group principal (e.g. github_org://123).
+	SubjectKindGroup SubjectKind = "Group"
+	// SubjectKindPrincipal binds an external principal that is resolved to a Rancher user or group.
+	SubjectKindPrincipal SubjectKind = "Principal"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkspaceMembership grants a subject a role in a FleetWorkspace.
+type WorkspaceMembership struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WorkspaceMembershipSpec   `json:"spec"`
+	Status WorkspaceMembershipStatus `json:"status,omitempty"`
+}
+
+type WorkspaceMembershipSpec struct {
+	// Workspace is the name of the FleetWorkspace.
+	Workspace string `json:"workspace" column:"name=Workspace,type=string,jsonpath=.spec.workspace"`
+
+	// Subject is the user, group or principal that is granted the role.
+	Subject Subject `json:"subject"`
+
+	// Role is a role name from the workspace role catalog.
+	Role string `json:"role" column:"name=Role,type=string,jsonpath=.spec.role"`
+}
+
+type Subject struct {
+	// Kind is one of User, Group or Principal.
+	Kind SubjectKind `json:"kind" column:"name=Kind,type=string,jsonpath=.spec.subject.kind"`
+
+	// Name is the Rancher user ID for User subjects and the principal ID for Group and Principal subjects.
+	Name string `json:"name" column:"name=Subject,type=string,jsonpath=.spec.subject.name"`
+}
+
+type WorkspaceMembershipStatus struct {
+	// GlobalRoleBindingName is the GlobalRoleBinding created for this membership.
+	GlobalRoleBindingName string `json:"globalRoleBindingName,omitempty"`
+
+	// ResolvedUser is the Rancher user ID the subject resolved to, empty for groups.
+	ResolvedUser string `json:"resolvedUser,omitempty"`
+
+	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkspaceTemplate is a set of Fleet resources seeded into a FleetWorkspace namespace
+// when the workspace is created.
+type WorkspaceTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WorkspaceTemplateSpec `json:"spec"`
+}
+
+type WorkspaceTemplateSpec struct {
+	// WorkspaceSelector selects the FleetWorkspaces using this template by label. Workspaces
+	// naming a template in the gorizond-template annotation ignore selectors, an empty
+	// selector matches every workspace.
+	WorkspaceSelector *metav1.LabelSelector `json:"workspaceSelector,omitempty"`
+
+	ClusterGroups       []TemplateClusterGroup       `json:"clusterGroups,omitempty"`
+	GitRepoRestrictions []TemplateGitRepoRestriction `json:"gitRepoRestrictions,omitempty"`
+	GitRepos            []TemplateGitRepo            `json:"gitRepos,omitempty"`
+}
+
+type TemplateClusterGroup struct {
+	Name   string                   `json:"name"`
+	Labels map[string]string        `json:"labels,omitempty"`
+	Spec   fleetv1.ClusterGroupSpec `json:"spec"`
+}
+
+// TemplateGitRepoRestriction mirrors the fields of a Fleet GitRepoRestriction.
+type TemplateGitRepoRestriction struct {
+	Name                     string            `json:"name"`
+	Labels                   map[string]string `json:"labels,omitempty"`
+	DefaultServiceAccount    string            `json:"defaultServiceAccount,omitempty"`
+	AllowedServiceAccounts   []string          `json:"allowedServiceAccounts,omitempty"`
+	AllowedRepoPatterns      []string          `json:"allowedRepoPatterns,omitempty"`
+	DefaultClientSecretName  string            `json:"defaultClientSecretName,omitempty"`
+	AllowedClientSecretNames []string          `json:"allowedClientSecretNames,omitempty"`
+	AllowedTargetNamespaces  []string          `json:"allowedTargetNamespaces,omitempty"`
+}
+
+type TemplateGitRepo struct {
+	Name   string              `json:"name"`
+	Labels map[string]string   `json:"labels,omitempty"`
+	Spec   fleetv1.GitRepoSpec `json:"spec"`
+}
+
+// AccessRequestDecision is the answer of a workspace admin to an access request.
+type AccessRequestDecision string
+
+const (
+	AccessRequestApproved AccessRequestDecision = "Approved"
+	AccessRequestDenied   AccessRequestDecision = "Denied"
+)
+
+// AccessRequestPhase is the state of an access request.
+type AccessRequestPhase string
+
+const (
+	AccessRequestPhasePending  AccessRequestPhase = "Pending"
+	AccessRequestPhaseApproved AccessRequestPhase = "Approved"
+	AccessRequestPhaseDenied   AccessRequestPhase = "Denied"
+	AccessRequestPhaseExpired  AccessRequestPhase = "Expired"
+	// AccessRequestPhaseRejected is an approval the controller refused, because the approver
+	// is the requester or not an admin of the workspace.
+	AccessRequestPhaseRejected AccessRequestPhase = "Rejected"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkspaceAccessRequest asks for a role in a FleetWorkspace, a workspace admin approves or
+// denies it by setting the decision.
+type WorkspaceAccessRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WorkspaceAccessRequestSpec   `json:"spec"`
+	Status WorkspaceAccessRequestStatus `json:"status,omitempty"`
+}
+
+type WorkspaceAccessRequestSpec struct {
+	// Workspace is the name of the FleetWorkspace.
+	Workspace string `json:"workspace" column:"name=Workspace,type=string,jsonpath=.spec.workspace"`
+
+	// Requester is the Rancher user ID asking for access. The webhook only lets admins name
+	// someone other than the authenticated user.
+	Requester string `json:"requester" column:"name=Requester,type=string,jsonpath=.spec.requester"`
+
+	// Role is a role name from the workspace role catalog.
+	Role string `json:"role" column:"name=Role,type=string,jsonpath=.spec.role"`
+
+	// Reason tells the admins why access is needed.
+	Reason string `json:"reason,omitempty"`
+
+	// ExpiresAfter expires the request when it is not decided in time.
+	ExpiresAfter *metav1.Duration `json:"expiresAfter,omitempty"`
+
+	// AccessDuration limits the granted membership, it is set as the gorizond-expires annotation
+	// of the membership on approval.
+	AccessDuration *metav1.Duration `json:"accessDuration,omitempty"`
+
+	// Decision is set by a workspace admin to Approved or Denied.
+	Decision AccessRequestDecision `json:"decision,omitempty"`
+
+	// DecidedBy is the Rancher user ID of the admin who set the decision.
+	DecidedBy string `json:"decidedBy,omitempty"`
+}
+
+type WorkspaceAccessRequestStatus struct {
+	Phase AccessRequestPhase `json:"phase,omitempty" column:"name=Phase,type=string,jsonpath=.status.phase"`
+
+	// CompletedAt is when the request was approved, denied or expired.
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+
+	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
+}
+
+// InviteeKind is how an invitation recognizes the invited user.
+type InviteeKind string
+
+const (
+	// InviteeKindLogin matches the login name of a user, local or from an auth provider.
+	InviteeKindLogin InviteeKind = "Login"
+	// InviteeKindEmail matches an email address an auth provider reported for a user.
+	InviteeKindEmail InviteeKind = "Email"
+	// InviteeKindPrincipal matches a principal ID of a user (e.g. github_user://123).
+	InviteeKindPrincipal InviteeKind = "Principal"
+)
+
+// InvitationPhase is the state of an invitation.
+type InvitationPhase string
+
+const (
+	InvitationPhasePending  InvitationPhase = "Pending"
+	InvitationPhaseAccepted InvitationPhase = "Accepted"
+	InvitationPhaseExpired  InvitationPhase = "Expired"
+	// InvitationPhaseRejected is an invitation the controller refused because the inviter
+	// does not administer the workspace.
+	InvitationPhaseRejected InvitationPhase = "Rejected"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkspaceInvitation grants a role in a FleetWorkspace to someone who may not have logged in
+// yet, it is accepted as soon as a Rancher user matching the invitee shows up.
+type WorkspaceInvitation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WorkspaceInvitationSpec   `json:"spec"`
+	Status WorkspaceInvitationStatus `json:"status,omitempty"`
+}
+
+type WorkspaceInvitationSpec struct {
+	// Workspace is the name of the FleetWorkspace.
+	Workspace string `json:"workspace" column:"name=Workspace,type=string,jsonpath=.spec.workspace"`
+
+	// Invitee identifies the invited user.
+	Invitee Invitee `json:"invitee"`
+
+	// Role is a role name from the workspace role catalog.
+	Role string `json:"role" column:"name=Role,type=string,jsonpath=.spec.role"`
+
+	// InvitedBy is the Rancher user ID of the inviting admin, the webhook checks it is the
+	// authenticated user and the controller that it still administers the workspace.
+	InvitedBy string `json:"invitedBy"`
+
+	// ExpiresAfter expires the invitation when nobody accepted it in time, it defaults to the
+	// controller's INVITATION_TTL.
+	ExpiresAfter *metav1.Duration `json:"expiresAfter,omitempty"`
+}
+
+type Invitee struct {
+	// Kind is one of Login, Email or Principal.
+	Kind InviteeKind `json:"kind" column:"name=Kind,type=string,jsonpath=.spec.invitee.kind"`
+
+	// Value is the login name, email address or principal ID.
+	Value string `json:"value" column:"name=Invitee,type=string,jsonpath=.spec.invitee.value"`
+}
+
+type WorkspaceInvitationStatus struct {
+	Phase InvitationPhase `json:"phase,omitempty" column:"name=Phase,type=string,jsonpath=.status.phase"`
+
+	// ExpiresAt is when a pending invitation expires.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// AcceptedBy is the Rancher user ID the invitation was bound to.
+	AcceptedBy string `json:"acceptedBy,omitempty"`
+
+	// CompletedAt is when the invitation was accepted or expired.
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+
+	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
+}
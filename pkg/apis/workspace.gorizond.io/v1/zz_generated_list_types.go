@@ -0,0 +1,77 @@
+// Code generated by controller-gen. DO NOT EDIT.
+
+// +k8s:deepcopy-gen=package
+// +groupName=workspace.gorizond.io
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkspaceMembershipList is a list of WorkspaceMembership resources
+type WorkspaceMembershipList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WorkspaceMembership `json:"items"`
+}
+
+func NewWorkspaceMembership(namespace, name string, obj WorkspaceMembership) *WorkspaceMembership {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("WorkspaceMembership").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkspaceTemplateList is a list of WorkspaceTemplate resources
+type WorkspaceTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WorkspaceTemplate `json:"items"`
+}
+
+func NewWorkspaceTemplate(namespace, name string, obj WorkspaceTemplate) *WorkspaceTemplate {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("WorkspaceTemplate").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkspaceAccessRequestList is a list of WorkspaceAccessRequest resources
+type WorkspaceAccessRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WorkspaceAccessRequest `json:"items"`
+}
+
+func NewWorkspaceAccessRequest(namespace, name string, obj WorkspaceAccessRequest) *WorkspaceAccessRequest {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("WorkspaceAccessRequest").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkspaceInvitationList is a list of WorkspaceInvitation resources
+type WorkspaceInvitationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WorkspaceInvitation `json:"items"`
+}
+
+func NewWorkspaceInvitation(namespace, name string, obj WorkspaceInvitation) *WorkspaceInvitation {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("WorkspaceInvitation").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
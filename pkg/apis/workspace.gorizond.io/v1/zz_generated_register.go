@@ -0,0 +1,53 @@
+// Code generated by controller-gen. DO NOT EDIT.
+
+// +k8s:deepcopy-gen=package
+// +groupName=workspace.gorizond.io
+package v1
+
+import (
+	workspace "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	WorkspaceAccessRequestResourceName = "workspaceaccessrequests"
+	WorkspaceInvitationResourceName    = "workspaceinvitations"
+	WorkspaceMembershipResourceName    = "workspacememberships"
+	WorkspaceTemplateResourceName      = "workspacetemplates"
+)
+
+// SchemeGroupVersion is group version used to register these objects
+var SchemeGroupVersion = schema.GroupVersion{Group: workspace.GroupName, Version: "v1"}
+
+// Kind takes an unqualified kind and returns back a Group qualified GroupKind
+func Kind(kind string) schema.GroupKind {
+	return SchemeGroupVersion.WithKind(kind).GroupKind()
+}
+
+// Resource takes an unqualified resource and returns a Group qualified GroupResource
+func Resource(resource string) schema.GroupResource {
+	return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
+
+var (
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	AddToScheme   = SchemeBuilder.AddToScheme
+)
+
+// Adds the list of known types to Scheme.
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&WorkspaceAccessRequest{},
+		&WorkspaceAccessRequestList{},
+		&WorkspaceInvitation{},
+		&WorkspaceInvitationList{},
+		&WorkspaceMembership{},
+		&WorkspaceMembershipList{},
+		&WorkspaceTemplate{},
+		&WorkspaceTemplateList{},
+	)
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
@@ -0,0 +1,46 @@
+package crds
+
+import (
+	"context"
+
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/crd"
+	"k8s.io/client-go/rest"
+)
+
+// Create installs or updates the CRDs served by the controller and waits until they are established.
+func Create(ctx context.Context, config *rest.Config) error {
+	factory, err := crd.NewFactoryFromClient(config)
+	if err != nil {
+		return err
+	}
+	return factory.BatchCreateCRDs(ctx, List()...).BatchWait()
+}
+
+// List returns the CRDs owned by the controller.
+func List() []crd.CRD {
+	return []crd.CRD{
+		crd.NonNamespacedType("WorkspaceMembership.workspace.gorizond.io/v1").
+			WithSchemaFromStruct(workspacev1.WorkspaceMembership{}).
+			WithColumnsFromStruct(workspacev1.WorkspaceMembership{}).
+			WithStatus().
+			WithCategories("gorizond").
+			WithShortNames("wsm"),
+		crd.NonNamespacedType("WorkspaceTemplate.workspace.gorizond.io/v1").
+			WithSchemaFromStruct(workspacev1.WorkspaceTemplate{}).
+			WithCategories("gorizond").
+			WithShortNames("wst"),
+		crd.NonNamespacedType("WorkspaceAccessRequest.workspace.gorizond.io/v1").
+			WithSchemaFromStruct(workspacev1.WorkspaceAccessRequest{}).
+			WithColumnsFromStruct(workspacev1.WorkspaceAccessRequest{}).
+			WithStatus().
+			WithCategories("gorizond").
+			WithShortNames("wsar"),
+		crd.NonNamespacedType("WorkspaceInvitation.workspace.gorizond.io/v1").
+			WithSchemaFromStruct(workspacev1.WorkspaceInvitation{}).
+			WithColumnsFromStruct(workspacev1.WorkspaceInvitation{}).
+			WithStatus().
+			WithCategories("gorizond").
+			WithShortNames("wsi"),
+	}
+}
@@ -20,6 +20,7 @@ type Interface interface {
 	GlobalRoleBinding() GlobalRoleBindingController
 	Principal() PrincipalController
 	User() UserController
+	UserAttribute() UserAttributeController
 }
 
 func New(controllerFactory controller.SharedControllerFactory) Interface {
@@ -51,3 +52,7 @@ func (v *version) Principal() PrincipalController {
 func (v *version) User() UserController {
 	return generic.NewNonNamespacedController[*v3.User, *v3.UserList](schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "User"}, "users", v.controllerFactory)
 }
+
+func (v *version) UserAttribute() UserAttributeController {
+	return generic.NewNonNamespacedController[*v3.UserAttribute, *v3.UserAttributeList](schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "UserAttribute"}, "userattributes", v.controllerFactory)
+}
@@ -0,0 +1,23 @@
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v3
+
+import (
+	v3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+)
+
+// UserAttributeController interface for managing UserAttribute resources.
+type UserAttributeController interface {
+	generic.NonNamespacedControllerInterface[*v3.UserAttribute, *v3.UserAttributeList]
+}
+
+// UserAttributeClient interface for managing UserAttribute resources in Kubernetes.
+type UserAttributeClient interface {
+	generic.NonNamespacedClientInterface[*v3.UserAttribute, *v3.UserAttributeList]
+}
+
+// UserAttributeCache interface for retrieving UserAttribute resources in memory.
+type UserAttributeCache interface {
+	generic.NonNamespacedCacheInterface[*v3.UserAttribute]
+}
@@ -0,0 +1,27 @@
+// Code generated by controller-gen. DO NOT EDIT.
+
+package workspace
+
+import (
+	v1 "github.com/gorizond/fleet-workspace-controller/pkg/generated/controllers/workspace.gorizond.io/v1"
+	"github.com/rancher/lasso/pkg/controller"
+)
+
+type Interface interface {
+	V1() v1.Interface
+}
+
+type group struct {
+	controllerFactory controller.SharedControllerFactory
+}
+
+// New returns a new Interface.
+func New(controllerFactory controller.SharedControllerFactory) Interface {
+	return &group{
+		controllerFactory: controllerFactory,
+	}
+}
+
+func (g *group) V1() v1.Interface {
+	return v1.New(g.controllerFactory)
+}
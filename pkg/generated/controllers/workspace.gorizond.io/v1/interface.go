@@ -0,0 +1,48 @@
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/rancher/lasso/pkg/controller"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/rancher/wrangler/v3/pkg/schemes"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func init() {
+	schemes.Register(v1.AddToScheme)
+}
+
+type Interface interface {
+	WorkspaceAccessRequest() WorkspaceAccessRequestController
+	WorkspaceInvitation() WorkspaceInvitationController
+	WorkspaceMembership() WorkspaceMembershipController
+	WorkspaceTemplate() WorkspaceTemplateController
+}
+
+func New(controllerFactory controller.SharedControllerFactory) Interface {
+	return &version{
+		controllerFactory: controllerFactory,
+	}
+}
+
+type version struct {
+	controllerFactory controller.SharedControllerFactory
+}
+
+func (v *version) WorkspaceAccessRequest() WorkspaceAccessRequestController {
+	return generic.NewNonNamespacedController[*v1.WorkspaceAccessRequest, *v1.WorkspaceAccessRequestList](schema.GroupVersionKind{Group: "workspace.gorizond.io", Version: "v1", Kind: "WorkspaceAccessRequest"}, "workspaceaccessrequests", v.controllerFactory)
+}
+
+func (v *version) WorkspaceInvitation() WorkspaceInvitationController {
+	return generic.NewNonNamespacedController[*v1.WorkspaceInvitation, *v1.WorkspaceInvitationList](schema.GroupVersionKind{Group: "workspace.gorizond.io", Version: "v1", Kind: "WorkspaceInvitation"}, "workspaceinvitations", v.controllerFactory)
+}
+
+func (v *version) WorkspaceMembership() WorkspaceMembershipController {
+	return generic.NewNonNamespacedController[*v1.WorkspaceMembership, *v1.WorkspaceMembershipList](schema.GroupVersionKind{Group: "workspace.gorizond.io", Version: "v1", Kind: "WorkspaceMembership"}, "workspacememberships", v.controllerFactory)
+}
+
+func (v *version) WorkspaceTemplate() WorkspaceTemplateController {
+	return generic.NewNonNamespacedController[*v1.WorkspaceTemplate, *v1.WorkspaceTemplateList](schema.GroupVersionKind{Group: "workspace.gorizond.io", Version: "v1", Kind: "WorkspaceTemplate"}, "workspacetemplates", v.controllerFactory)
+}
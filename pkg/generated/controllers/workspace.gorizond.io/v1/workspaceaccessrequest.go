@@ -0,0 +1,192 @@
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/rancher/wrangler/v3/pkg/kv"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WorkspaceAccessRequestController interface for managing WorkspaceAccessRequest resources.
+type WorkspaceAccessRequestController interface {
+	generic.NonNamespacedControllerInterface[*v1.WorkspaceAccessRequest, *v1.WorkspaceAccessRequestList]
+}
+
+// WorkspaceAccessRequestClient interface for managing WorkspaceAccessRequest resources in Kubernetes.
+type WorkspaceAccessRequestClient interface {
+	generic.NonNamespacedClientInterface[*v1.WorkspaceAccessRequest, *v1.WorkspaceAccessRequestList]
+}
+
+// WorkspaceAccessRequestCache interface for retrieving WorkspaceAccessRequest resources in memory.
+type WorkspaceAccessRequestCache interface {
+	generic.NonNamespacedCacheInterface[*v1.WorkspaceAccessRequest]
+}
+
+// WorkspaceAccessRequestStatusHandler is executed for every added or modified WorkspaceAccessRequest. Should return the new status to be updated
+type WorkspaceAccessRequestStatusHandler func(obj *v1.WorkspaceAccessRequest, status v1.WorkspaceAccessRequestStatus) (v1.WorkspaceAccessRequestStatus, error)
+
+// WorkspaceAccessRequestGeneratingHandler is the top-level handler that is executed for every WorkspaceAccessRequest event. It extends WorkspaceAccessRequestStatusHandler by a returning a slice of child objects to be passed to apply.Apply
+type WorkspaceAccessRequestGeneratingHandler func(obj *v1.WorkspaceAccessRequest, status v1.WorkspaceAccessRequestStatus) ([]runtime.Object, v1.WorkspaceAccessRequestStatus, error)
+
+// RegisterWorkspaceAccessRequestStatusHandler configures a WorkspaceAccessRequestController to execute a WorkspaceAccessRequestStatusHandler for every events observed.
+// If a non-empty condition is provided, it will be updated in the status conditions for every handler execution
+func RegisterWorkspaceAccessRequestStatusHandler(ctx context.Context, controller WorkspaceAccessRequestController, condition condition.Cond, name string, handler WorkspaceAccessRequestStatusHandler) {
+	statusHandler := &workspaceAccessRequestStatusHandler{
+		client:    controller,
+		condition: condition,
+		handler:   handler,
+	}
+	controller.AddGenericHandler(ctx, name, generic.FromObjectHandlerToHandler(statusHandler.sync))
+}
+
+// RegisterWorkspaceAccessRequestGeneratingHandler configures a WorkspaceAccessRequestController to execute a WorkspaceAccessRequestGeneratingHandler for every events observed, passing the returned objects to the provided apply.Apply.
+// If a non-empty condition is provided, it will be updated in the status conditions for every handler execution
+func RegisterWorkspaceAccessRequestGeneratingHandler(ctx context.Context, controller WorkspaceAccessRequestController, apply apply.Apply,
+	condition condition.Cond, name string, handler WorkspaceAccessRequestGeneratingHandler, opts *generic.GeneratingHandlerOptions) {
+	statusHandler := &workspaceAccessRequestGeneratingHandler{
+		WorkspaceAccessRequestGeneratingHandler: handler,
+		apply:                                   apply,
+		name:                                    name,
+		gvk:                                     controller.GroupVersionKind(),
+	}
+	if opts != nil {
+		statusHandler.opts = *opts
+	}
+	controller.OnChange(ctx, name, statusHandler.Remove)
+	RegisterWorkspaceAccessRequestStatusHandler(ctx, controller, condition, name, statusHandler.Handle)
+}
+
+type workspaceAccessRequestStatusHandler struct {
+	client    WorkspaceAccessRequestClient
+	condition condition.Cond
+	handler   WorkspaceAccessRequestStatusHandler
+}
+
+// sync is executed on every resource addition or modification. Executes the configured handlers and sends the updated status to the Kubernetes API
+func (a *workspaceAccessRequestStatusHandler) sync(key string, obj *v1.WorkspaceAccessRequest) (*v1.WorkspaceAccessRequest, error) {
+	if obj == nil {
+		return obj, nil
+	}
+
+	origStatus := obj.Status.DeepCopy()
+	obj = obj.DeepCopy()
+	newStatus, err := a.handler(obj, obj.Status)
+	if err != nil {
+		// Revert to old status on error
+		newStatus = *origStatus.DeepCopy()
+	}
+
+	if a.condition != "" {
+		if errors.IsConflict(err) {
+			a.condition.SetError(&newStatus, "", nil)
+		} else {
+			a.condition.SetError(&newStatus, "", err)
+		}
+	}
+	if !equality.Semantic.DeepEqual(origStatus, &newStatus) {
+		if a.condition != "" {
+			// Since status has changed, update the lastUpdatedTime
+			a.condition.LastUpdated(&newStatus, time.Now().UTC().Format(time.RFC3339))
+		}
+
+		var newErr error
+		obj.Status = newStatus
+		newObj, newErr := a.client.UpdateStatus(obj)
+		if err == nil {
+			err = newErr
+		}
+		if newErr == nil {
+			obj = newObj
+		}
+	}
+	return obj, err
+}
+
+type workspaceAccessRequestGeneratingHandler struct {
+	WorkspaceAccessRequestGeneratingHandler
+	apply apply.Apply
+	opts  generic.GeneratingHandlerOptions
+	gvk   schema.GroupVersionKind
+	name  string
+	seen  sync.Map
+}
+
+// Remove handles the observed deletion of a resource, cascade deleting every associated resource previously applied
+func (a *workspaceAccessRequestGeneratingHandler) Remove(key string, obj *v1.WorkspaceAccessRequest) (*v1.WorkspaceAccessRequest, error) {
+	if obj != nil {
+		return obj, nil
+	}
+
+	obj = &v1.WorkspaceAccessRequest{}
+	obj.Namespace, obj.Name = kv.RSplit(key, "/")
+	obj.SetGroupVersionKind(a.gvk)
+
+	if a.opts.UniqueApplyForResourceVersion {
+		a.seen.Delete(key)
+	}
+
+	return nil, generic.ConfigureApplyForObject(a.apply, obj, &a.opts).
+		WithOwner(obj).
+		WithSetID(a.name).
+		ApplyObjects()
+}
+
+// Handle executes the configured WorkspaceAccessRequestGeneratingHandler and pass the resulting objects to apply.Apply, finally returning the new status of the resource
+func (a *workspaceAccessRequestGeneratingHandler) Handle(obj *v1.WorkspaceAccessRequest, status v1.WorkspaceAccessRequestStatus) (v1.WorkspaceAccessRequestStatus, error) {
+	if !obj.DeletionTimestamp.IsZero() {
+		return status, nil
+	}
+
+	objs, newStatus, err := a.WorkspaceAccessRequestGeneratingHandler(obj, status)
+	if err != nil {
+		return newStatus, err
+	}
+	if !a.isNewResourceVersion(obj) {
+		return newStatus, nil
+	}
+
+	err = generic.ConfigureApplyForObject(a.apply, obj, &a.opts).
+		WithOwner(obj).
+		WithSetID(a.name).
+		ApplyObjects(objs...)
+	if err != nil {
+		return newStatus, err
+	}
+	a.storeResourceVersion(obj)
+	return newStatus, nil
+}
+
+// isNewResourceVersion detects if a specific resource version was already successfully processed.
+// Only used if UniqueApplyForResourceVersion is set in generic.GeneratingHandlerOptions
+func (a *workspaceAccessRequestGeneratingHandler) isNewResourceVersion(obj *v1.WorkspaceAccessRequest) bool {
+	if !a.opts.UniqueApplyForResourceVersion {
+		return true
+	}
+
+	// Apply once per resource version
+	key := obj.Namespace + "/" + obj.Name
+	previous, ok := a.seen.Load(key)
+	return !ok || previous != obj.ResourceVersion
+}
+
+// storeResourceVersion keeps track of the latest resource version of an object for which Apply was executed
+// Only used if UniqueApplyForResourceVersion is set in generic.GeneratingHandlerOptions
+func (a *workspaceAccessRequestGeneratingHandler) storeResourceVersion(obj *v1.WorkspaceAccessRequest) {
+	if !a.opts.UniqueApplyForResourceVersion {
+		return
+	}
+
+	key := obj.Namespace + "/" + obj.Name
+	a.seen.Store(key, obj.ResourceVersion)
+}
@@ -0,0 +1,192 @@
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/rancher/wrangler/v3/pkg/kv"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WorkspaceMembershipController interface for managing WorkspaceMembership resources.
+type WorkspaceMembershipController interface {
+	generic.NonNamespacedControllerInterface[*v1.WorkspaceMembership, *v1.WorkspaceMembershipList]
+}
+
+// WorkspaceMembershipClient interface for managing WorkspaceMembership resources in Kubernetes.
+type WorkspaceMembershipClient interface {
+	generic.NonNamespacedClientInterface[*v1.WorkspaceMembership, *v1.WorkspaceMembershipList]
+}
+
+// WorkspaceMembershipCache interface for retrieving WorkspaceMembership resources in memory.
+type WorkspaceMembershipCache interface {
+	generic.NonNamespacedCacheInterface[*v1.WorkspaceMembership]
+}
+
+// WorkspaceMembershipStatusHandler is executed for every added or modified WorkspaceMembership. Should return the new status to be updated
+type WorkspaceMembershipStatusHandler func(obj *v1.WorkspaceMembership, status v1.WorkspaceMembershipStatus) (v1.WorkspaceMembershipStatus, error)
+
+// WorkspaceMembershipGeneratingHandler is the top-level handler that is executed for every WorkspaceMembership event. It extends WorkspaceMembershipStatusHandler by a returning a slice of child objects to be passed to apply.Apply
+type WorkspaceMembershipGeneratingHandler func(obj *v1.WorkspaceMembership, status v1.WorkspaceMembershipStatus) ([]runtime.Object, v1.WorkspaceMembershipStatus, error)
+
+// RegisterWorkspaceMembershipStatusHandler configures a WorkspaceMembershipController to execute a WorkspaceMembershipStatusHandler for every events observed.
+// If a non-empty condition is provided, it will be updated in the status conditions for every handler execution
+func RegisterWorkspaceMembershipStatusHandler(ctx context.Context, controller WorkspaceMembershipController, condition condition.Cond, name string, handler WorkspaceMembershipStatusHandler) {
+	statusHandler := &workspaceMembershipStatusHandler{
+		client:    controller,
+		condition: condition,
+		handler:   handler,
+	}
+	controller.AddGenericHandler(ctx, name, generic.FromObjectHandlerToHandler(statusHandler.sync))
+}
+
+// RegisterWorkspaceMembershipGeneratingHandler configures a WorkspaceMembershipController to execute a WorkspaceMembershipGeneratingHandler for every events observed, passing the returned objects to the provided apply.Apply.
+// If a non-empty condition is provided, it will be updated in the status conditions for every handler execution
+func RegisterWorkspaceMembershipGeneratingHandler(ctx context.Context, controller WorkspaceMembershipController, apply apply.Apply,
+	condition condition.Cond, name string, handler WorkspaceMembershipGeneratingHandler, opts *generic.GeneratingHandlerOptions) {
+	statusHandler := &workspaceMembershipGeneratingHandler{
+		WorkspaceMembershipGeneratingHandler: handler,
+		apply:                                apply,
+		name:                                 name,
+		gvk:                                  controller.GroupVersionKind(),
+	}
+	if opts != nil {
+		statusHandler.opts = *opts
+	}
+	controller.OnChange(ctx, name, statusHandler.Remove)
+	RegisterWorkspaceMembershipStatusHandler(ctx, controller, condition, name, statusHandler.Handle)
+}
+
+type workspaceMembershipStatusHandler struct {
+	client    WorkspaceMembershipClient
+	condition condition.Cond
+	handler   WorkspaceMembershipStatusHandler
+}
+
+// sync is executed on every resource addition or modification. Executes the configured handlers and sends the updated status to the Kubernetes API
+func (a *workspaceMembershipStatusHandler) sync(key string, obj *v1.WorkspaceMembership) (*v1.WorkspaceMembership, error) {
+	if obj == nil {
+		return obj, nil
+	}
+
+	origStatus := obj.Status.DeepCopy()
+	obj = obj.DeepCopy()
+	newStatus, err := a.handler(obj, obj.Status)
+	if err != nil {
+		// Revert to old status on error
+		newStatus = *origStatus.DeepCopy()
+	}
+
+	if a.condition != "" {
+		if errors.IsConflict(err) {
+			a.condition.SetError(&newStatus, "", nil)
+		} else {
+			a.condition.SetError(&newStatus, "", err)
+		}
+	}
+	if !equality.Semantic.DeepEqual(origStatus, &newStatus) {
+		if a.condition != "" {
+			// Since status has changed, update the lastUpdatedTime
+			a.condition.LastUpdated(&newStatus, time.Now().UTC().Format(time.RFC3339))
+		}
+
+		var newErr error
+		obj.Status = newStatus
+		newObj, newErr := a.client.UpdateStatus(obj)
+		if err == nil {
+			err = newErr
+		}
+		if newErr == nil {
+			obj = newObj
+		}
+	}
+	return obj, err
+}
+
+type workspaceMembershipGeneratingHandler struct {
+	WorkspaceMembershipGeneratingHandler
+	apply apply.Apply
+	opts  generic.GeneratingHandlerOptions
+	gvk   schema.GroupVersionKind
+	name  string
+	seen  sync.Map
+}
+
+// Remove handles the observed deletion of a resource, cascade deleting every associated resource previously applied
+func (a *workspaceMembershipGeneratingHandler) Remove(key string, obj *v1.WorkspaceMembership) (*v1.WorkspaceMembership, error) {
+	if obj != nil {
+		return obj, nil
+	}
+
+	obj = &v1.WorkspaceMembership{}
+	obj.Namespace, obj.Name = kv.RSplit(key, "/")
+	obj.SetGroupVersionKind(a.gvk)
+
+	if a.opts.UniqueApplyForResourceVersion {
+		a.seen.Delete(key)
+	}
+
+	return nil, generic.ConfigureApplyForObject(a.apply, obj, &a.opts).
+		WithOwner(obj).
+		WithSetID(a.name).
+		ApplyObjects()
+}
+
+// Handle executes the configured WorkspaceMembershipGeneratingHandler and pass the resulting objects to apply.Apply, finally returning the new status of the resource
+func (a *workspaceMembershipGeneratingHandler) Handle(obj *v1.WorkspaceMembership, status v1.WorkspaceMembershipStatus) (v1.WorkspaceMembershipStatus, error) {
+	if !obj.DeletionTimestamp.IsZero() {
+		return status, nil
+	}
+
+	objs, newStatus, err := a.WorkspaceMembershipGeneratingHandler(obj, status)
+	if err != nil {
+		return newStatus, err
+	}
+	if !a.isNewResourceVersion(obj) {
+		return newStatus, nil
+	}
+
+	err = generic.ConfigureApplyForObject(a.apply, obj, &a.opts).
+		WithOwner(obj).
+		WithSetID(a.name).
+		ApplyObjects(objs...)
+	if err != nil {
+		return newStatus, err
+	}
+	a.storeResourceVersion(obj)
+	return newStatus, nil
+}
+
+// isNewResourceVersion detects if a specific resource version was already successfully processed.
+// Only used if UniqueApplyForResourceVersion is set in generic.GeneratingHandlerOptions
+func (a *workspaceMembershipGeneratingHandler) isNewResourceVersion(obj *v1.WorkspaceMembership) bool {
+	if !a.opts.UniqueApplyForResourceVersion {
+		return true
+	}
+
+	// Apply once per resource version
+	key := obj.Namespace + "/" + obj.Name
+	previous, ok := a.seen.Load(key)
+	return !ok || previous != obj.ResourceVersion
+}
+
+// storeResourceVersion keeps track of the latest resource version of an object for which Apply was executed
+// Only used if UniqueApplyForResourceVersion is set in generic.GeneratingHandlerOptions
+func (a *workspaceMembershipGeneratingHandler) storeResourceVersion(obj *v1.WorkspaceMembership) {
+	if !a.opts.UniqueApplyForResourceVersion {
+		return
+	}
+
+	key := obj.Namespace + "/" + obj.Name
+	a.seen.Store(key, obj.ResourceVersion)
+}
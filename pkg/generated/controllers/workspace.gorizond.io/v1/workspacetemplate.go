@@ -0,0 +1,23 @@
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+)
+
+// WorkspaceTemplateController interface for managing WorkspaceTemplate resources.
+type WorkspaceTemplateController interface {
+	generic.NonNamespacedControllerInterface[*v1.WorkspaceTemplate, *v1.WorkspaceTemplateList]
+}
+
+// WorkspaceTemplateClient interface for managing WorkspaceTemplate resources in Kubernetes.
+type WorkspaceTemplateClient interface {
+	generic.NonNamespacedClientInterface[*v1.WorkspaceTemplate, *v1.WorkspaceTemplateList]
+}
+
+// WorkspaceTemplateCache interface for retrieving WorkspaceTemplate resources in memory.
+type WorkspaceTemplateCache interface {
+	generic.NonNamespacedCacheInterface[*v1.WorkspaceTemplate]
+}
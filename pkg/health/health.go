@@ -0,0 +1,118 @@
+// Package health serves the /healthz and /readyz probes.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds a single probe check.
+const checkTimeout = 5 * time.Second
+
+// Check reports a problem by returning an error.
+type Check func(ctx context.Context) error
+
+// Checks holds the liveness and readiness checks. The zero value is ready to use.
+type Checks struct {
+	mu    sync.RWMutex
+	live  map[string]Check
+	ready map[string]Check
+}
+
+// AddLive registers a check of /healthz. A failing liveness check gets the pod restarted.
+func (c *Checks) AddLive(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.live == nil {
+		c.live = map[string]Check{}
+	}
+	c.live[name] = check
+}
+
+// AddReady registers a check of /readyz.
+func (c *Checks) AddReady(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ready == nil {
+		c.ready = map[string]Check{}
+	}
+	c.ready[name] = check
+}
+
+// Install adds the /healthz and /readyz handlers to mux.
+func (c *Checks) Install(mux *http.ServeMux) {
+	mux.Handle("/healthz", c.handler(func() map[string]Check { return c.live }))
+	mux.Handle("/readyz", c.handler(func() map[string]Check { return c.ready }))
+}
+
+func (c *Checks) handler(checks func() map[string]Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.RLock()
+		names := make([]string, 0, len(checks()))
+		for name := range checks() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var failures []string
+		for _, name := range names {
+			ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+			if err := checks()[name](ctx); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			}
+			cancel()
+		}
+		c.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(failures) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, strings.Join(failures, "\n"))
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// Heartbeat is a liveness check for a worker queue: something enqueued every interval must be
+// processed and call Beat, otherwise the queue is considered stuck after timeout.
+type Heartbeat struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	last    time.Time
+	now     func() time.Time
+}
+
+// NewHeartbeat returns a heartbeat that passes until Start is called.
+func NewHeartbeat(timeout time.Duration) *Heartbeat {
+	return &Heartbeat{timeout: timeout, now: time.Now}
+}
+
+// Start arms the heartbeat, it is called once the queue is running.
+func (h *Heartbeat) Start() {
+	h.Beat()
+}
+
+// Beat records that the queue processed an item.
+func (h *Heartbeat) Beat() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last = h.now()
+}
+
+// Check fails when the last beat is older than the timeout.
+func (h *Heartbeat) Check(context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.last.IsZero() {
+		return nil
+	}
+	if since := h.now().Sub(h.last); since > h.timeout {
+		return fmt.Errorf("no heartbeat for %s", since.Round(time.Second))
+	}
+	return nil
+}
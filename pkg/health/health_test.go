@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChecksHandler(t *testing.T) {
+	var checks Checks
+	checks.AddLive("queue", func(context.Context) error { return nil })
+	checks.AddReady("caches", func(context.Context) error { return errors.New("caches not synced: User") })
+	mux := http.NewServeMux()
+	checks.Install(mux)
+
+	for path, want := range map[string]int{"/healthz": http.StatusOK, "/readyz": http.StatusServiceUnavailable} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+		if want != http.StatusOK && !strings.Contains(rec.Body.String(), "caches: caches not synced: User") {
+			t.Fatalf("%s: failure not reported, got %q", path, rec.Body.String())
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewHeartbeat(time.Minute)
+	h.now = func() time.Time { return now }
+
+	now = now.Add(time.Hour)
+	if err := h.Check(context.Background()); err != nil {
+		t.Fatalf("heartbeat must pass before it is started: %v", err)
+	}
+	h.Start()
+	now = now.Add(30 * time.Second)
+	if err := h.Check(context.Background()); err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := h.Check(context.Background()); err == nil {
+		t.Fatalf("expected a stuck queue to fail")
+	}
+	h.Beat()
+	if err := h.Check(context.Background()); err != nil {
+		t.Fatalf("unexpected failure after beat: %v", err)
+	}
+}
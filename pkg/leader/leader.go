@@ -0,0 +1,125 @@
+// Package leader runs the controllers under a Lease lock so that only one replica reconciles.
+package leader
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rancher/lasso/pkg/log"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Options configures leader election.
+type Options struct {
+	Enabled       bool
+	Namespace     string
+	Name          string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// DefaultOptions returns the options used when no flag is given.
+func DefaultOptions(namespace string) Options {
+	return Options{
+		Enabled:       true,
+		Namespace:     namespace,
+		Name:          "fleet-workspace-controller",
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	}
+}
+
+// AddFlags registers the leader election flags on fs.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.Enabled, "leader-elect", o.Enabled, "Elect a leader before starting the controllers, required with more than one replica")
+	fs.StringVar(&o.Namespace, "leader-election-namespace", o.Namespace, "Namespace of the leader election Lease")
+	fs.StringVar(&o.Name, "leader-election-id", o.Name, "Name of the leader election Lease")
+	fs.DurationVar(&o.LeaseDuration, "leader-election-lease-duration", o.LeaseDuration, "How long standbys wait before taking over a lease that is not renewed")
+	fs.DurationVar(&o.RenewDeadline, "leader-election-renew-deadline", o.RenewDeadline, "How long the leader retries renewing the lease before giving up leadership")
+	fs.DurationVar(&o.RetryPeriod, "leader-election-retry-period", o.RetryPeriod, "How long to wait between attempts to acquire or renew the lease")
+}
+
+func (o *Options) validate() error {
+	if o.Namespace == "" || o.Name == "" {
+		return fmt.Errorf("leader election namespace and id are required")
+	}
+	if o.LeaseDuration <= o.RenewDeadline {
+		return fmt.Errorf("leader election lease duration %s must be greater than the renew deadline %s", o.LeaseDuration, o.RenewDeadline)
+	}
+	if o.RetryPeriod <= 0 || o.RenewDeadline <= o.RetryPeriod {
+		return fmt.Errorf("leader election renew deadline %s must be greater than the retry period %s", o.RenewDeadline, o.RetryPeriod)
+	}
+	return nil
+}
+
+// Run calls run once this replica holds the lease and blocks until ctx is done.
+// run must block until its context is done. The lease is released when ctx is cancelled,
+// so a standby takes over right away on SIGTERM. Losing the lease for any other reason
+// exits the process, as the controllers cannot be stopped cleanly.
+func Run(ctx context.Context, config *rest.Config, opts Options, run func(ctx context.Context)) error {
+	if !opts.Enabled {
+		run(ctx)
+		return nil
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	identity := hostname + "_" + string(uuid.NewUUID())
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, opts.Namespace, opts.Name,
+		client.CoreV1(), client.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: identity})
+	if err != nil {
+		return err
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   opts.LeaseDuration,
+		RenewDeadline:   opts.RenewDeadline,
+		RetryPeriod:     opts.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            opts.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Infof("Became leader %s, starting controllers", identity)
+				run(ctx)
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() != nil {
+					log.Infof("Released leader lease %s/%s", opts.Namespace, opts.Name)
+					return
+				}
+				log.Errorf("Lost leader lease %s/%s, exiting", opts.Namespace, opts.Name)
+				os.Exit(1)
+			},
+			OnNewLeader: func(current string) {
+				if current != identity {
+					log.Infof("Waiting for leader lease %s/%s held by %s", opts.Namespace, opts.Name, current)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	elector.Run(ctx)
+	return nil
+}
@@ -0,0 +1,49 @@
+package leader
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestOptionsFlags(t *testing.T) {
+	opts := DefaultOptions("cattle-fleet-system")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts.AddFlags(fs)
+	if err := fs.Parse([]string{"--leader-election-namespace=gorizond", "--leader-election-lease-duration=30s"}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Namespace != "gorizond" || opts.LeaseDuration != 30*time.Second || opts.Name != "fleet-workspace-controller" {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if err := opts.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	for name, mutate := range map[string]func(*Options){
+		"no namespace":             func(o *Options) { o.Namespace = "" },
+		"lease shorter than renew": func(o *Options) { o.LeaseDuration = 5 * time.Second },
+		"renew shorter than retry": func(o *Options) { o.RetryPeriod = 10 * time.Second },
+	} {
+		opts := DefaultOptions("cattle-fleet-system")
+		mutate(&opts)
+		if err := opts.validate(); err == nil {
+			t.Fatalf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestRunDisabled(t *testing.T) {
+	opts := DefaultOptions("cattle-fleet-system")
+	opts.Enabled = false
+	ran := false
+	if err := Run(context.Background(), nil, opts, func(context.Context) { ran = true }); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Fatalf("expected run to be called without leader election")
+	}
+}
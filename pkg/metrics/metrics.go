@@ -0,0 +1,97 @@
+// Package metrics holds the Prometheus metrics of the controller and serves them on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gorizond"
+
+// Registry holds every metric of the controller, separate from the global default registry.
+var Registry = prometheus.NewRegistry()
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_total",
+		Help:      "Number of reconciles per handler.",
+	}, []string{"handler"})
+
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of reconciles per handler that returned an error.",
+	}, []string{"handler"})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Reconcile latency per handler.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	rancherRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rancher_api_request_duration_seconds",
+		Help:      "Latency of Rancher REST API requests by endpoint and status code, retries counted separately.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "endpoint", "code"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		reconcileTotal,
+		reconcileErrors,
+		reconcileDuration,
+		rancherRequestDuration,
+	)
+}
+
+// Handler serves the metrics of Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Instrument wraps a wrangler OnChange or OnRemove handler, counting its reconciles,
+// errors and latency under the handler name.
+func Instrument[T any](handler string, fn func(string, T) (T, error)) func(string, T) (T, error) {
+	total := reconcileTotal.WithLabelValues(handler)
+	errors := reconcileErrors.WithLabelValues(handler)
+	duration := reconcileDuration.WithLabelValues(handler)
+	return func(key string, obj T) (T, error) {
+		start := time.Now()
+		result, err := fn(key, obj)
+		duration.Observe(time.Since(start).Seconds())
+		total.Inc()
+		if err != nil {
+			errors.Inc()
+		}
+		return result, err
+	}
+}
+
+// ObserveReconcile records a reconcile of a handler that cannot be wrapped by Instrument.
+func ObserveReconcile(handler string, start time.Time, err error) {
+	reconcileDuration.WithLabelValues(handler).Observe(time.Since(start).Seconds())
+	reconcileTotal.WithLabelValues(handler).Inc()
+	if err != nil {
+		reconcileErrors.WithLabelValues(handler).Inc()
+	}
+}
+
+// ObserveRancherRequest records one Rancher API request. code is 0 when no response arrived.
+func ObserveRancherRequest(method, endpoint string, code int, duration time.Duration) {
+	status := "error"
+	if code != 0 {
+		status = strconv.Itoa(code)
+	}
+	rancherRequestDuration.WithLabelValues(method, endpoint, status).Observe(duration.Seconds())
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	handler := Instrument("test-handler", func(key string, obj *string) (*string, error) {
+		if key == "fail" {
+			return nil, errors.New("boom")
+		}
+		return obj, nil
+	})
+	value := "ok"
+	if got, err := handler("ok", &value); err != nil || got != &value {
+		t.Fatalf("handler result not passed through: %v %v", got, err)
+	}
+	if _, err := handler("fail", nil); err == nil {
+		t.Fatalf("handler error not passed through")
+	}
+
+	if got := testutil.ToFloat64(reconcileTotal.WithLabelValues("test-handler")); got != 2 {
+		t.Fatalf("expected 2 reconciles, got %v", got)
+	}
+	if got := testutil.ToFloat64(reconcileErrors.WithLabelValues("test-handler")); got != 1 {
+		t.Fatalf("expected 1 error, got %v", got)
+	}
+}
+
+func TestObserveRancherRequest(t *testing.T) {
+	ObserveRancherRequest("GET", "/v3/users", 503, time.Millisecond)
+	ObserveRancherRequest("GET", "/v3/users", 0, time.Millisecond)
+	if got := testutil.CollectAndCount(rancherRequestDuration); got != 2 {
+		t.Fatalf("expected series for 503 and error, got %d", got)
+	}
+}
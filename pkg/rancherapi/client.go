@@ -0,0 +1,271 @@
+// Package rancherapi is a small client for the parts of the Rancher v3 REST API
+// that have no Kubernetes counterpart, such as principal lookups.
+package rancherapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorizond/fleet-workspace-controller/pkg/metrics"
+)
+
+const (
+	defaultTimeout      = 30 * time.Second
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+	defaultMaxRetryTime = 2 * time.Minute
+)
+
+// Options configures a Client.
+type Options struct {
+	// URL is the Rancher server URL, e.g. https://rancher.example.com.
+	URL string
+	// Token is sent as a bearer token with every request.
+	Token string
+	// CAFile is a PEM bundle used to verify the Rancher certificate instead of the system roots.
+	CAFile string
+	// InsecureSkipVerify disables certificate verification. OptionsFromEnv sets it unless
+	// a CA bundle is configured, so by default the Rancher certificate is not verified.
+	InsecureSkipVerify bool
+	// Timeout bounds a single HTTP request, each retry gets its own.
+	Timeout time.Duration
+	// MaxRetries is how often a request failing with a transport error, 429 or 5xx is retried,
+	// zero means the default of 3 and a negative value disables retries.
+	MaxRetries int
+	// MaxRetryTime bounds a request with all its retries and backoff.
+	MaxRetryTime time.Duration
+	// RetryBackoff is the first retry delay, doubled on every further attempt.
+	RetryBackoff time.Duration
+}
+
+// OptionsFromEnv reads RANCHER_URL, RANCHER_TOKEN, RANCHER_CA_FILE, RANCHER_INSECURE_SKIP_VERIFY,
+// RANCHER_TIMEOUT and RANCHER_MAX_RETRY_TIME. Certificate verification stays disabled, as
+// InsecureSkipVerify, unless a CA bundle is given or RANCHER_INSECURE_SKIP_VERIFY is "false",
+// which matches the behaviour of earlier releases.
+func OptionsFromEnv() Options {
+	opts := Options{
+		URL:    os.Getenv("RANCHER_URL"),
+		Token:  os.Getenv("RANCHER_TOKEN"),
+		CAFile: os.Getenv("RANCHER_CA_FILE"),
+	}
+	switch os.Getenv("RANCHER_INSECURE_SKIP_VERIFY") {
+	case "true":
+		opts.InsecureSkipVerify = true
+	case "":
+		opts.InsecureSkipVerify = opts.CAFile == ""
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("RANCHER_TIMEOUT")); err == nil {
+		opts.Timeout = timeout
+	}
+	if maxRetryTime, err := time.ParseDuration(os.Getenv("RANCHER_MAX_RETRY_TIME")); err == nil {
+		opts.MaxRetryTime = maxRetryTime
+	}
+	return opts
+}
+
+// Client talks to the Rancher v3 API. It is safe for concurrent use.
+type Client struct {
+	base         *url.URL
+	token        string
+	http         *http.Client
+	timeout      time.Duration
+	maxRetries   int
+	maxRetryTime time.Duration
+	retryBackoff time.Duration
+}
+
+// New builds a Client sharing one transport across all requests.
+// A client without URL is valid, its requests fail with ErrNotConfigured.
+func New(opts Options) (*Client, error) {
+	c := &Client{
+		token:        opts.Token,
+		timeout:      opts.Timeout,
+		maxRetries:   opts.MaxRetries,
+		maxRetryTime: opts.MaxRetryTime,
+		retryBackoff: opts.RetryBackoff,
+	}
+	if c.timeout == 0 {
+		c.timeout = defaultTimeout
+	}
+	switch {
+	case c.maxRetries == 0:
+		c.maxRetries = defaultMaxRetries
+	case c.maxRetries < 0:
+		c.maxRetries = 0
+	}
+	if c.maxRetryTime == 0 {
+		c.maxRetryTime = defaultMaxRetryTime
+	}
+	if c.retryBackoff == 0 {
+		c.retryBackoff = defaultRetryBackoff
+	}
+	if opts.URL != "" {
+		base, err := url.Parse(strings.TrimSuffix(opts.URL, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rancher URL %q: %w", opts.URL, err)
+		}
+		c.base = base
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rancher CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in rancher CA bundle %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	c.http = &http.Client{Transport: transport}
+	return c, nil
+}
+
+// Principal is an entry of /v3/principals.
+type Principal struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	LoginName     string `json:"loginName"`
+	PrincipalType string `json:"principalType"`
+	Provider      string `json:"provider"`
+}
+
+// GetPrincipal returns the principal with the given ID, e.g. github_user://123.
+func (c *Client) GetPrincipal(ctx context.Context, id string) (*Principal, error) {
+	var principal Principal
+	if err := c.get(ctx, "/v3/principals/"+url.PathEscape(id), &principal); err != nil {
+		return nil, err
+	}
+	return &principal, nil
+}
+
+// Ping checks with a single request, without retries, that Rancher answers and accepts the token.
+func (c *Client) Ping(ctx context.Context) error {
+	if c.base == nil {
+		return ErrNotConfigured
+	}
+	endpoint := *c.base
+	endpoint.Path += "/v3/principals"
+	endpoint.RawPath = ""
+	endpoint.RawQuery = "limit=1"
+	_, _, _, err := c.once(ctx, http.MethodGet, endpoint.String())
+	return err
+}
+
+// get fetches an escaped path relative to the Rancher URL and decodes the JSON answer.
+func (c *Client) get(ctx context.Context, path string, into interface{}) error {
+	if c.base == nil {
+		return ErrNotConfigured
+	}
+	escaped := c.base.EscapedPath() + path
+	unescaped, err := url.PathUnescape(escaped)
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	endpoint := *c.base
+	endpoint.Path = unescaped
+	endpoint.RawPath = escaped
+
+	body, err := c.do(ctx, http.MethodGet, endpoint.String(), collectionPath(path))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, into); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", endpoint.Path, err)
+	}
+	return nil
+}
+
+// collectionPath reduces an API path to its collection, /v3/principals/local:%2F%2Fu-abc to /v3/principals,
+// to keep metric labels bounded.
+func collectionPath(path string) string {
+	parts := strings.SplitN(path, "/", 4)
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, "/")
+}
+
+// do runs a request, retrying transport errors, 429 and 5xx responses with exponential backoff
+// for at most maxRetryTime.
+func (c *Client) do(ctx context.Context, method, endpoint, collection string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.maxRetryTime)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		body, code, retryAfter, err := c.once(ctx, method, endpoint)
+		metrics.ObserveRancherRequest(method, collection, code, time.Since(start))
+		if err == nil || !isRetryable(err) || attempt >= c.maxRetries || ctx.Err() != nil {
+			return body, err
+		}
+
+		wait := c.retryBackoff << attempt
+		if wait > maxRetryBackoff || wait <= 0 {
+			wait = maxRetryBackoff
+		}
+		if retryAfter > 0 {
+			wait = min(retryAfter, maxRetryBackoff)
+		}
+		if time.Until(deadline) < wait {
+			// the next attempt could not start in time, report the last failure
+			return body, err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// once runs a single request bounded by the client timeout and returns the body and status
+// code of a 2xx answer.
+func (c *Client) once(ctx context.Context, method, endpoint string) ([]byte, int, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, 0, &TransportError{Method: method, URL: endpoint, Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, 0, &TransportError{Method: method, URL: endpoint, Err: err}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, resp.StatusCode, retryAfter, newStatusError(method, endpoint, resp.StatusCode, body)
+	}
+	return body, resp.StatusCode, 0, nil
+}
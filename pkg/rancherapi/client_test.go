@@ -0,0 +1,168 @@
+package rancherapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := New(Options{URL: server.URL, Token: "token:secret", RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestGetPrincipal(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/v3/principals/github_user:%2F%2F42" {
+			t.Errorf("unexpected path %s", r.URL.EscapedPath())
+		}
+		if r.Header.Get("Authorization") != "Bearer token:secret" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		fmt.Fprint(w, `{"id":"github_user://42","loginName":"octocat","principalType":"user"}`)
+	})
+
+	principal, err := c.GetPrincipal(context.Background(), "github_user://42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.LoginName != "octocat" || principal.PrincipalType != "user" {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"id":"local://u-abc"}`)
+	})
+	if _, err := c.GetPrincipal(context.Background(), "local://u-abc"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+
+	calls = 0
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(unavailable.Close)
+	c, err := New(Options{URL: unavailable.URL, MaxRetries: -1, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPrincipal(context.Background(), "local://u-abc"); err == nil {
+		t.Fatalf("expected the status error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected no retry with retries disabled, got %d calls", calls)
+	}
+
+	calls = 0
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"type":"error","code":"NotFound","message":"principal local://u-missing not found"}`)
+	})
+	_, err = c.GetPrincipal(context.Background(), "local://u-missing")
+	if !IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != "NotFound" {
+		t.Fatalf("expected typed status error, got %#v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected no retry on 404, got %d calls", calls)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// hang until the client gives up on the attempt
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, `{"id":"local://u-abc"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	c, err := New(Options{URL: server.URL, Timeout: 50 * time.Millisecond, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPrincipal(context.Background(), "local://u-abc"); err != nil {
+		t.Fatalf("expected the retry after a timed out attempt to succeed, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+
+	calls = 0
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(unavailable.Close)
+	c, err = New(Options{URL: unavailable.URL, MaxRetries: 100, RetryBackoff: 20 * time.Millisecond, MaxRetryTime: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = c.GetPrincipal(context.Background(), "local://u-abc")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected the last status error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond || calls >= 100 {
+		t.Fatalf("retries took %v and %d calls, want them capped at about 100ms", elapsed, calls)
+	}
+}
+
+func TestNotConfigured(t *testing.T) {
+	c, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPrincipal(context.Background(), "local://u-abc"); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") != "Bearer token:secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	err := c.Ping(context.Background())
+	if err == nil || IsUnauthorized(err) {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("ping must not retry, got %d calls", calls)
+	}
+}
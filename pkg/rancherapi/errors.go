@@ -0,0 +1,85 @@
+package rancherapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotConfigured is returned by a Client created without a Rancher URL.
+var ErrNotConfigured = errors.New("rancher API URL is not configured")
+
+// StatusError is returned when Rancher answers with a non-2xx status.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Code and Message come from the Rancher API error body when present.
+	Code    string
+	Message string
+}
+
+func newStatusError(method, url string, status int, body []byte) *StatusError {
+	err := &StatusError{Method: method, URL: url, StatusCode: status}
+	var apiErr struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil {
+		err.Code = apiErr.Code
+		err.Message = apiErr.Message
+	}
+	return err
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("rancher API %s %s: unexpected status code %d", e.Method, e.URL, e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// TransportError wraps a failure to reach Rancher or read its response.
+type TransportError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("rancher API %s %s: %v", e.Method, e.URL, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound reports whether err is a 404 answer from Rancher.
+func IsNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+// IsUnauthorized reports whether Rancher rejected the token.
+func IsUnauthorized(err error) bool {
+	code := statusCode(err)
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
+func statusCode(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+func isRetryable(err error) bool {
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		return true
+	}
+	code := statusCode(err)
+	return code == http.StatusTooManyRequests || code >= 500
+}
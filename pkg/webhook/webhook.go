@@ -0,0 +1,132 @@
+// Package webhook serves validating admission webhooks over TLS.
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rancher/lasso/pkg/log"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// maxRequestBytes bounds an AdmissionReview body, the API server sends at most a few MB.
+const maxRequestBytes = 6 << 20
+
+// Validator checks an admission request. Field errors deny the request, an error fails it.
+type Validator func(ctx context.Context, req *admissionv1.AdmissionRequest) (field.ErrorList, error)
+
+// Handler serves AdmissionReview requests with validate.
+func Handler(validate Validator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		review := &admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(review); err != nil {
+			http.Error(w, fmt.Sprintf("invalid AdmissionReview: %v", err), http.StatusBadRequest)
+			return
+		}
+		if review.Request == nil {
+			http.Error(w, "AdmissionReview has no request", http.StatusBadRequest)
+			return
+		}
+
+		response := &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+		errs, err := validate(r.Context(), review.Request)
+		switch {
+		case err != nil:
+			log.Errorf("Failed to validate %s %s/%s: %v", review.Request.Operation, review.Request.Resource.Resource, review.Request.Name, err)
+			response.Allowed = false
+			response.Result = &metav1.Status{Status: metav1.StatusFailure, Code: http.StatusInternalServerError, Reason: metav1.StatusReasonInternalError, Message: err.Error()}
+		case len(errs) > 0:
+			response.Allowed = false
+			response.Result = &metav1.Status{Status: metav1.StatusFailure, Code: http.StatusUnprocessableEntity, Reason: metav1.StatusReasonInvalid, Message: errs.ToAggregate().Error()}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&admissionv1.AdmissionReview{
+			TypeMeta: review.TypeMeta,
+			Response: response,
+		})
+	})
+}
+
+// certificate loads tls.crt and tls.key from dir and reloads them when they change on disk,
+// so rotated certificates are picked up without a restart.
+type certificate struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	modTime time.Time
+	cert    *tls.Certificate
+}
+
+func newCertificate(dir string) (*certificate, error) {
+	c := &certificate{certFile: filepath.Join(dir, "tls.crt"), keyFile: filepath.Join(dir, "tls.key")}
+	if _, err := c.get(nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		if c.cert != nil {
+			return c.cert, nil
+		}
+		return nil, err
+	}
+	if c.cert != nil && !info.ModTime().After(c.modTime) {
+		return c.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			log.Errorf("Keeping the previous webhook certificate: %v", err)
+			return c.cert, nil
+		}
+		return nil, err
+	}
+	c.cert = &cert
+	c.modTime = info.ModTime()
+	return c.cert, nil
+}
+
+// Serve runs an HTTPS server on addr with the certificate in certDir until ctx is done.
+func Serve(ctx context.Context, addr, certDir string, handler http.Handler) error {
+	cert, err := newCertificate(certDir)
+	if err != nil {
+		return fmt.Errorf("failed to load webhook certificate: %w", err)
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: cert.get},
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Webhook server on %s stopped: %v", addr, err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func review(t *testing.T, handler http.Handler, name string) *admissionv1.AdmissionResponse {
+	t.Helper()
+	body, err := json.Marshal(&admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{UID: "uid-1", Name: name}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	result := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Response == nil || result.Response.UID != "uid-1" {
+		t.Fatalf("response does not answer the request: %+v", result.Response)
+	}
+	return result.Response
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler(func(ctx context.Context, req *admissionv1.AdmissionRequest) (field.ErrorList, error) {
+		switch req.Name {
+		case "invalid":
+			return field.ErrorList{field.Invalid(field.NewPath("metadata", "name"), req.Name, "bad name")}, nil
+		case "broken":
+			return nil, errors.New("catalog unavailable")
+		}
+		return nil, nil
+	})
+
+	if response := review(t, handler, "valid"); !response.Allowed {
+		t.Fatalf("expected valid object to be allowed: %+v", response.Result)
+	}
+	response := review(t, handler, "invalid")
+	if response.Allowed || response.Result.Code != http.StatusUnprocessableEntity || !strings.Contains(response.Result.Message, "bad name") {
+		t.Fatalf("expected invalid object to be denied, got %+v", response)
+	}
+	response = review(t, handler, "broken")
+	if response.Allowed || response.Result.Code != http.StatusInternalServerError {
+		t.Fatalf("expected validation errors to deny, got %+v", response)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader("{}")))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected review without request to be rejected, got %d", rec.Code)
+	}
+}
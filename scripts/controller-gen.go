@@ -2,6 +2,7 @@ package main
 
 import (
 	v3 "github.com/gorizond/fleet-workspace-controller/pkg/apis/management.cattle.io/v3"
+	workspacev1 "github.com/gorizond/fleet-workspace-controller/pkg/apis/workspace.gorizond.io/v1"
 	controllergen "github.com/rancher/wrangler/v3/pkg/controller-gen"
 	"github.com/rancher/wrangler/v3/pkg/controller-gen/args"
 )
@@ -19,6 +20,17 @@ func main() {
 					v3.User{},
 					v3.GlobalRole{},
 					v3.Principal{},
+					v3.UserAttribute{},
+				},
+				GenerateTypes: true,
+			},
+			"workspace.gorizond.io": {
+				PackageName: "workspace.gorizond.io",
+				Types: []interface{}{
+					workspacev1.WorkspaceMembership{},
+					workspacev1.WorkspaceTemplate{},
+					workspacev1.WorkspaceAccessRequest{},
+					workspacev1.WorkspaceInvitation{},
 				},
 				GenerateTypes: true,
 			},